@@ -14,9 +14,10 @@ import (
 
 type writeMessage struct {
 	// TODO: create a custom []byte buffer instance so we do not need to keep the reference to the pool?
-	buf   *buffer.Buffer
-	ent   zapcore.Entry
-	flush chan struct{}
+	buf    *buffer.Buffer
+	ent    zapcore.Entry
+	fields Fields
+	flush  chan struct{}
 }
 
 var ErrAppenderShutdown = errors.New("appender shut down")
@@ -81,14 +82,20 @@ func (a *Async) start() {
 
 // the return value n does not work in an async context
 func (a *Async) Write(p []byte, ent zapcore.Entry) (n int, err error) {
+	return a.WriteFields(p, ent, Fields{})
+}
+
+// WriteFields queues the message with its fields. The fields are an immutable snapshot and safe to retain.
+func (a *Async) WriteFields(p []byte, ent zapcore.Entry, fields Fields) (n int, err error) {
 	if atomic.LoadInt32(&a.shutdown) != 0 {
 		err = ErrAppenderShutdown
 		return
 	}
 
 	msg := writeMessage{
-		buf: bufferpool.Get(),
-		ent: ent,
+		buf:    bufferpool.Get(),
+		ent:    ent,
+		fields: fields,
 	}
 
 	n, err = msg.buf.Write(p)
@@ -119,7 +126,7 @@ func (a *Async) forwardWrite() {
 				continue
 			}
 			// TODO: handle error
-			_, _ = a.primary.Write(msg.buf.Bytes(), msg.ent)
+			_, _ = writeWithFields(a.primary, msg.buf.Bytes(), msg.ent, msg.fields)
 			msg.buf.Free()
 		}
 	}
@@ -144,7 +151,7 @@ func (a *Async) monitorQueueWrite() {
 					continue
 				}
 				// TODO: drop or Fallback: add messageFullStrategy
-				a.fallback.Write(msg.buf.Bytes(), msg.ent)
+				writeWithFields(a.fallback, msg.buf.Bytes(), msg.ent, msg.fields)
 				msg.buf.Free()
 			}
 		}
@@ -186,6 +193,10 @@ func (a *Async) Drain(ctx context.Context) {
 	}
 }
 
+func (a *Async) WantsFields() bool {
+	return WantsFields(a.primary) || WantsFields(a.fallback)
+}
+
 func (a *Async) Synchronized() bool {
 	return true
 }
@@ -0,0 +1,174 @@
+package zapappender
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// batchItem is a message waiting to be sent.
+type batchItem struct {
+	buf *buffer.Buffer
+	ent zapcore.Entry
+}
+
+// batchFlushFn sends the items of a batch.
+// The buffers of the items are freed after it returned.
+type batchFlushFn func(key string, items []batchItem) error
+
+// batcher collects encoded messages in batches per key.
+//
+// A batch is flushed when it reaches the maximum number of items or bytes, when the flush interval elapsed
+// or when flush is called. Flushes are serialized, so the order of the messages per key is kept.
+type batcher struct {
+	// readonly
+	maxItems int
+	maxBytes int
+	flushFn  batchFlushFn
+
+	// state
+	mu       sync.Mutex
+	pending  map[string]*pendingBatch
+	flushMu  sync.Mutex
+	errMu    sync.Mutex
+	err      error // returned by the next flush call
+	close    chan struct{}
+	shutdown int32 // incremented by stop
+}
+
+type pendingBatch struct {
+	items []batchItem
+	size  int
+}
+
+func newBatcher(maxItems, maxBytes int, interval time.Duration, flushFn batchFlushFn) *batcher {
+	b := &batcher{
+		maxItems: maxItems,
+		maxBytes: maxBytes,
+		flushFn:  flushFn,
+		pending:  make(map[string]*pendingBatch),
+		close:    make(chan struct{}),
+	}
+	if interval > 0 {
+		go b.flushPeriodically(interval)
+	}
+	return b
+}
+
+// add takes ownership of buf.
+// If the batch of key is full, it is flushed and the result is returned.
+func (b *batcher) add(key string, buf *buffer.Buffer, ent zapcore.Entry) error {
+	b.mu.Lock()
+	batch, ok := b.pending[key]
+	if !ok {
+		batch = &pendingBatch{}
+		b.pending[key] = batch
+	}
+	overflows := b.maxBytes > 0 && len(batch.items) > 0 && batch.size+buf.Len() > b.maxBytes
+	b.mu.Unlock()
+
+	var err error
+	if overflows {
+		err = b.flushKey(key)
+	}
+
+	b.mu.Lock()
+	batch, ok = b.pending[key]
+	if !ok {
+		batch = &pendingBatch{}
+		b.pending[key] = batch
+	}
+	batch.items = append(batch.items, batchItem{buf: buf, ent: ent})
+	batch.size += buf.Len()
+	full := len(batch.items) >= b.maxItems || (b.maxBytes > 0 && batch.size >= b.maxBytes)
+	b.mu.Unlock()
+
+	if full {
+		err = multierr.Append(err, b.flushKey(key))
+	}
+	return err
+}
+
+// flushKey sends the pending batch of key.
+func (b *batcher) flushKey(key string) error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	b.mu.Lock()
+	batch, ok := b.pending[key]
+	delete(b.pending, key)
+	b.mu.Unlock()
+
+	if !ok || len(batch.items) == 0 {
+		return nil
+	}
+	return b.send(key, batch.items)
+}
+
+// flush sends all pending batches.
+// It also returns the errors of the periodic flushes since the last call.
+func (b *batcher) flush() error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	b.mu.Lock()
+	pending := b.pending
+	b.pending = make(map[string]*pendingBatch)
+	b.mu.Unlock()
+
+	b.errMu.Lock()
+	err := b.err
+	b.err = nil
+	b.errMu.Unlock()
+
+	for key, batch := range pending {
+		err = multierr.Append(err, b.send(key, batch.items))
+	}
+	return err
+}
+
+// send must be called with flushMu held
+func (b *batcher) send(key string, items []batchItem) error {
+	err := b.flushFn(key, items)
+	for i := range items {
+		items[i].buf.Free()
+		items[i] = batchItem{}
+	}
+	return err
+}
+
+func (b *batcher) flushPeriodically(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-b.close:
+			return
+		}
+		b.mu.Lock()
+		empty := len(b.pending) == 0
+		b.mu.Unlock()
+		if empty {
+			continue
+		}
+		if err := b.flush(); err != nil {
+			b.errMu.Lock()
+			b.err = multierr.Append(b.err, err)
+			b.errMu.Unlock()
+		}
+	}
+}
+
+// stop ends the periodic flushing and flushes the pending batches.
+func (b *batcher) stop() error {
+	if atomic.SwapInt32(&b.shutdown, 1) != 0 {
+		return nil // already called
+	}
+	close(b.close)
+	return b.flush()
+}
@@ -71,6 +71,10 @@ func BenchmarkFallbackEnveloping(b *testing.B) {
 				})
 				RunWithAppender(a, b, config)
 			})
+			b.Run("redacting no match", func(b *testing.B) {
+				a, _ := zapappender.NewRedacting(writer, zapappender.RedactDefaults())
+				RunWithAppender(a, b, config)
+			})
 			b.Run("chained_no_async", func(b *testing.B) {
 				var a zapappender.Appender = writer
 				a = zapappender.NewEnvelopingPreSuffix(a, "prefix: ", "")
@@ -0,0 +1,76 @@
+// Command verifyhashchain verifies logs written by zapappender.HashChain.
+//
+// Usage:
+//
+//	verifyhashchain [-key-file file] [-allow-restarts] log...
+//
+// The key file contains the raw HMAC key; without it, plain SHA-256 chains are verified.
+// A log appended to by several processes contains a new chain for every restart. Restarts are reported
+// as broken links unless -allow-restarts is given. They are not linked to the previous chain, so with
+// -allow-restarts, records removed right before a restart cannot be detected.
+// The exit code is 1 if a chain is broken and 2 on usage or read errors.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/delixfe/zapappender"
+)
+
+func main() {
+	keyFile := flag.String("key-file", "", "file containing the HMAC key")
+	allowRestarts := flag.Bool("allow-restarts", false, "accept restarts of the writing process, which are not linked to the previous chain")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-key-file file] [-allow-restarts] log...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var options []zapappender.HashChainOption
+	if *keyFile != "" {
+		key, err := os.ReadFile(*keyFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		options = append(options, zapappender.HashChainHMAC(key))
+	}
+	if *allowRestarts {
+		options = append(options, zapappender.HashChainAllowRestarts())
+	}
+
+	exitCode := 0
+	for _, name := range flag.Args() {
+		err := verify(name, options)
+		var chainErr *zapappender.HashChainError
+		switch {
+		case err == nil:
+			fmt.Printf("%s: ok\n", name)
+		case errors.As(err, &chainErr):
+			fmt.Printf("%s: %v\n", name, err)
+			if exitCode == 0 {
+				exitCode = 1
+			}
+		default:
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			exitCode = 2
+		}
+	}
+	os.Exit(exitCode)
+}
+
+func verify(name string, options []zapappender.HashChainOption) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return zapappender.VerifyHashChain(f, options...)
+}
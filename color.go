@@ -0,0 +1,243 @@
+package zapappender
+
+import (
+	"bytes"
+	"errors"
+	"os"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/term"
+)
+
+const colorReset = "\x1b[0m"
+
+// ColorPalette maps levels to ANSI SGR sequences, e.g. "\x1b[31m" for red.
+// Levels without an entry are not colored.
+type ColorPalette map[zapcore.Level]string
+
+// DefaultColorPalette returns the palette used by zap's color level encoders.
+func DefaultColorPalette() ColorPalette {
+	return ColorPalette{
+		zapcore.DebugLevel:  "\x1b[35m",
+		zapcore.InfoLevel:   "\x1b[34m",
+		zapcore.WarnLevel:   "\x1b[33m",
+		zapcore.ErrorLevel:  "\x1b[31m",
+		zapcore.DPanicLevel: "\x1b[31m",
+		zapcore.PanicLevel:  "\x1b[31m",
+		zapcore.FatalLevel:  "\x1b[31m",
+	}
+}
+
+type colorConfig struct {
+	palette     ColorPalette
+	prefix      bool
+	force       *bool
+	keepEscapes bool
+}
+
+type ColorOption interface {
+	apply(*colorConfig) error
+}
+
+type colorOptionsFunc func(*colorConfig) error
+
+func (f colorOptionsFunc) apply(c *colorConfig) error {
+	return f(c)
+}
+
+// ColorLevelPalette sets the colors per level. The default is DefaultColorPalette.
+func ColorLevelPalette(palette ColorPalette) ColorOption {
+	return colorOptionsFunc(func(c *colorConfig) error {
+		if palette == nil {
+			return errors.New("palette must not be nil")
+		}
+		c.palette = palette
+		return nil
+	})
+}
+
+// ColorLevelPrefix prepends the colored upper case level to the message instead of coloring the whole line.
+func ColorLevelPrefix() ColorOption {
+	return colorOptionsFunc(func(c *colorConfig) error {
+		c.prefix = true
+		return nil
+	})
+}
+
+// ColorForce enables or disables coloring regardless of the terminal detection and NO_COLOR.
+func ColorForce(enabled bool) ColorOption {
+	return colorOptionsFunc(func(c *colorConfig) error {
+		c.force = &enabled
+		return nil
+	})
+}
+
+// ColorKeepEscapes passes escape sequences contained in the message through.
+// By default, they are stripped so that untrusted content cannot manipulate the terminal.
+func ColorKeepEscapes() ColorOption {
+	return colorOptionsFunc(func(c *colorConfig) error {
+		c.keepEscapes = true
+		return nil
+	})
+}
+
+// NewColor creates an Enveloping coloring messages by level with ANSI escape sequences.
+//
+// Coloring is enabled if inner is a Writer whose target is a terminal, the NO_COLOR environment
+// variable is not set and TERM is not "dumb". The Writer may be wrapped in Synchronizing, Async
+// or Enveloping appenders. ColorForce overrides the detection.
+// Escape sequences and control characters within the messages are stripped even if coloring is disabled.
+// With ColorLevelPrefix, the level prefix is written uncolored if coloring is disabled.
+func NewColor(inner Appender, options ...ColorOption) (*Enveloping, error) {
+	if inner == nil {
+		return nil, errors.New("inner is required")
+	}
+	config := &colorConfig{
+		palette: DefaultColorPalette(),
+	}
+	for _, option := range options {
+		if err := option.apply(config); err != nil {
+			return nil, err
+		}
+	}
+
+	enabled := os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb" && isTerminal(inner)
+	if config.force != nil {
+		enabled = *config.force
+	}
+	return NewEnveloping(inner, newColorEnvelopingFn(config, enabled)), nil
+}
+
+func newColorEnvelopingFn(config *colorConfig, enabled bool) EnvelopingFn {
+	appendPayload := func(output *buffer.Buffer, payload []byte) {
+		if config.keepEscapes {
+			_, _ = output.Write(payload)
+			return
+		}
+		appendStrippedEscapes(output, payload)
+	}
+	return func(p []byte, ent zapcore.Entry, output *buffer.Buffer) error {
+		color := ""
+		if enabled {
+			color = config.palette[ent.Level]
+		}
+		payload := trimNewline(p)
+		switch {
+		case config.prefix:
+			if color != "" {
+				output.AppendString(color)
+				output.AppendString(ent.Level.CapitalString())
+				output.AppendString(colorReset)
+			} else {
+				output.AppendString(ent.Level.CapitalString())
+			}
+			output.AppendByte(' ')
+			appendPayload(output, payload)
+		case color != "":
+			output.AppendString(color)
+			appendPayload(output, payload)
+			output.AppendString(colorReset)
+		default:
+			appendPayload(output, payload)
+		}
+		_, _ = output.Write(p[len(payload):])
+		return nil
+	}
+}
+
+// isTerminal reports whether a is a Writer to a terminal.
+// Synchronizing, Async and Enveloping appenders are unwrapped.
+func isTerminal(a Appender) bool {
+	for {
+		switch inner := a.(type) {
+		case *Synchronizing:
+			a = inner.primary
+			continue
+		case *Async:
+			a = inner.primary
+			continue
+		case *Enveloping:
+			a = inner.primary
+			continue
+		}
+		break
+	}
+	w, ok := a.(*Writer)
+	if !ok {
+		return false
+	}
+	f, ok := w.out.(interface{ Fd() uintptr })
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+// appendStrippedEscapes appends p without ANSI escape sequences and control characters.
+// CSI (including the 8-bit form U+009B), OSC, DCS and two byte sequences are removed.
+// Other C0 control characters and DEL are removed as well, except tab and newline.
+func appendStrippedEscapes(output *buffer.Buffer, p []byte) {
+	if !containsControl(p) && !bytes.Contains(p, []byte("\xc2\x9b")) {
+		_, _ = output.Write(p)
+		return
+	}
+	for i := 0; i < len(p); {
+		c := p[i]
+		switch {
+		case c == 0x1b && i+1 < len(p) && p[i+1] == '[':
+			i = skipCSI(p, i+2)
+		case c == 0xc2 && i+1 < len(p) && p[i+1] == 0x9b:
+			i = skipCSI(p, i+2)
+		case c == 0x1b && i+1 < len(p) && (p[i+1] == ']' || p[i+1] == 'P' || p[i+1] == '_' || p[i+1] == '^'):
+			i = skipString(p, i+2)
+		case c == 0x1b:
+			// two byte sequence or a lone escape at the end
+			i += 2
+		case isStrippedControl(c):
+			i++
+		default:
+			output.AppendByte(c)
+			i++
+		}
+	}
+}
+
+func containsControl(p []byte) bool {
+	for _, c := range p {
+		if c == 0x1b || isStrippedControl(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// isStrippedControl reports whether c is a C0 control character other than tab and newline, or DEL.
+func isStrippedControl(c byte) bool {
+	return (c < 0x20 && c != '\t' && c != '\n') || c == 0x7f
+}
+
+// skipCSI returns the index after the control sequence whose parameters start at i.
+func skipCSI(p []byte, i int) int {
+	for i < len(p) && p[i] >= 0x20 && p[i] <= 0x3f {
+		i++
+	}
+	if i < len(p) && p[i] >= 0x40 && p[i] <= 0x7e {
+		i++
+	}
+	return i
+}
+
+// skipString returns the index after the string terminated by BEL or ST starting at i.
+func skipString(p []byte, i int) int {
+	for i < len(p) {
+		switch {
+		case p[i] == 0x07:
+			return i + 1
+		case p[i] == 0x1b && i+1 < len(p) && p[i+1] == '\\':
+			return i + 2
+		case p[i] == '\n':
+			// do not swallow the rest of the message if the string is not terminated
+			return i
+		}
+		i++
+	}
+	return i
+}
@@ -0,0 +1,101 @@
+package zapappender_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/delixfe/zapappender"
+	"github.com/delixfe/zapappender/internal"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestColor(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  []zapappender.ColorOption
+		level    zapcore.Level
+		payload  string
+		expected string
+	}{
+		{"line", []zapappender.ColorOption{zapappender.ColorForce(true)},
+			zapcore.ErrorLevel, "failed\n", "\x1b[31mfailed\x1b[0m\n"},
+		{"prefix", []zapappender.ColorOption{zapappender.ColorForce(true), zapappender.ColorLevelPrefix()},
+			zapcore.WarnLevel, "careful\n", "\x1b[33mWARN\x1b[0m careful\n"},
+		{"palette without level", []zapappender.ColorOption{zapappender.ColorForce(true),
+			zapappender.ColorLevelPalette(zapappender.ColorPalette{zapcore.ErrorLevel: "\x1b[1;31m"})},
+			zapcore.InfoLevel, "hello\n", "hello\n"},
+		{"disabled strips escapes", []zapappender.ColorOption{zapappender.ColorForce(false)},
+			zapcore.InfoLevel, "a\x1b[2Jb\x1b]0;title\x07c\xc2\x9b1md\n", "abcd\n"},
+		{"disabled prefix", []zapappender.ColorOption{zapappender.ColorForce(false), zapappender.ColorLevelPrefix()},
+			zapcore.InfoLevel, "hello\n", "INFO hello\n"},
+		{"disabled strips control characters", []zapappender.ColorOption{zapappender.ColorForce(false)},
+			zapcore.InfoLevel, "a\rb\bc\x00d\x7fe\tf\n", "abcde\tf\n"},
+		{"keep escapes", []zapappender.ColorOption{zapappender.ColorForce(false), zapappender.ColorKeepEscapes()},
+			zapcore.InfoLevel, "a\x1b[1mb\n", "a\x1b[1mb\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &internal.Buffer{}
+			a, err := zapappender.NewColor(zapappender.NewWriter(out), tt.options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := a.Write([]byte(tt.payload), zapcore.Entry{Level: tt.level}); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestColor_notTerminal_disabled(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	a, err := zapappender.NewColor(zapappender.NewWriter(f))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Write([]byte("failed\n"), zapcore.Entry{Level: zapcore.ErrorLevel}); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := os.ReadFile(f.Name())
+	if string(content) != "failed\n" {
+		t.Errorf("expected uncolored output, got %q", content)
+	}
+}
+
+func TestColor_wrappedNullDevice_disabled(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("TERM", "xterm")
+	f, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer f.Close()
+
+	// the null device is a character device but not a terminal
+	var written string
+	capture := zapappender.NewEnveloping(zapappender.NewWriter(f), func(p []byte, ent zapcore.Entry, output *buffer.Buffer) error {
+		written = string(p)
+		_, _ = output.Write(p)
+		return nil
+	})
+	a, err := zapappender.NewColor(zapappender.NewSynchronizing(capture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Write([]byte("failed\n"), zapcore.Entry{Level: zapcore.ErrorLevel}); err != nil {
+		t.Fatal(err)
+	}
+	if written != "failed\n" {
+		t.Errorf("expected uncolored output, got %q", written)
+	}
+}
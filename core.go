@@ -3,6 +3,7 @@ package zapappender
 import (
 	"sync"
 
+	"go.uber.org/zap/buffer"
 	"go.uber.org/zap/zapcore"
 )
 
@@ -23,6 +24,8 @@ var _ zapcore.Core = &AppenderCore{}
 // - we cannot keep the fields in an async process
 //	- they might hold references that might be already mutated or hinder GC
 // - without fields, we cannot use the Encoder to encode the message
+//
+// Appenders that need the fields nevertheless can implement FieldsAware to receive a copied snapshot.
 type Appender interface {
 
 	// Write
@@ -75,6 +78,16 @@ func (s *Synchronizing) Write(p []byte, ent zapcore.Entry) (n int, err error) {
 	return s.primary.Write(p, ent)
 }
 
+func (s *Synchronizing) WriteFields(p []byte, ent zapcore.Entry, fields Fields) (n int, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return writeWithFields(s.primary, p, ent, fields)
+}
+
+func (s *Synchronizing) WantsFields() bool {
+	return WantsFields(s.primary)
+}
+
 func (s *Synchronizing) Sync() error {
 	//TODO: should we lock Sync?
 	return s.primary.Sync()
@@ -87,31 +100,44 @@ func (s *Synchronizing) Synchronized() bool {
 var _ zapcore.Core = &AppenderCore{}
 
 // AppenderCore bridges between zapcore and zapappender.
+//
+// If the appender wants fields (see FieldsAware), the fields are collected into a snapshot while they are
+// encoded, so marshalers are still called once. The fields added with With are merged with those of
+// the entry only when accessed.
 type AppenderCore struct {
 	zapcore.LevelEnabler
-	enc      zapcore.Encoder
-	appender Appender
+	enc         zapcore.Encoder
+	appender    Appender
+	wantsFields bool
+	fields      fieldsContext
 }
 
 func NewAppenderCore(enc zapcore.Encoder, appender Appender, enab zapcore.LevelEnabler) *AppenderCore {
-
+	appender = NewSynchronizing(appender)
 	return &AppenderCore{
 		LevelEnabler: enab,
 		enc:          enc,
-		appender:     NewSynchronizing(appender),
+		appender:     appender,
+		wantsFields:  WantsFields(appender),
 	}
 }
 
 func (c *AppenderCore) With(fields []zapcore.Field) zapcore.Core {
 	enc := c.enc.Clone()
-	for i := range fields {
-		fields[i].AddTo(enc)
-	}
-	return &AppenderCore{
+	clone := &AppenderCore{
 		LevelEnabler: c.LevelEnabler,
 		appender:     c.appender,
 		enc:          enc,
+		wantsFields:  c.wantsFields,
 	}
+	if c.wantsFields {
+		clone.fields = c.fields.with(enc, fields)
+	} else {
+		for i := range fields {
+			fields[i].AddTo(enc)
+		}
+	}
+	return clone
 }
 
 func (c *AppenderCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
@@ -122,11 +148,24 @@ func (c *AppenderCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapco
 }
 
 func (c *AppenderCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
-	buf, err := c.enc.EncodeEntry(ent, fields)
+	var (
+		buf      *buffer.Buffer
+		snapshot Fields
+		err      error
+	)
+	if c.wantsFields {
+		buf, snapshot, err = c.fields.encodeEntry(c.enc, ent, fields)
+	} else {
+		buf, err = c.enc.EncodeEntry(ent, fields)
+	}
 	if err != nil {
 		return err
 	}
-	_, err = c.appender.Write(buf.Bytes(), ent)
+	if c.wantsFields {
+		_, err = writeWithFields(c.appender, buf.Bytes(), ent, snapshot)
+	} else {
+		_, err = c.appender.Write(buf.Bytes(), ent)
+	}
 	buf.Free()
 	if err != nil {
 		return err
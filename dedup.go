@@ -0,0 +1,204 @@
+package zapappender
+
+import (
+	"errors"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	_ SynchronizationAwareAppender = &Dedup{}
+	_ FieldsAwareAppender          = &Dedup{}
+)
+
+// Dedup suppresses repeated messages similar to syslogd.
+//
+// The first occurrence of a message is forwarded. Repetitions within the window are counted
+// and reported as "last message repeated N times" when the window closes, a different message
+// arrives or Sync is called. Errors writing the summary when the window closes are returned
+// by the next Write or Sync.
+//
+// By default, messages are considered equal if message, level and logger name of the entry are equal.
+// DedupByPayload compares a hash of the whole payload instead.
+type Dedup struct {
+	// readonly
+	primary        Appender
+	window         time.Duration
+	keyFn          func(p []byte, ent zapcore.Entry) dedupKey
+	summaryEncoder zapcore.Encoder
+	now            func() time.Time
+
+	// state
+	mu        sync.Mutex
+	last      dedupKey
+	lastEnt   zapcore.Entry
+	active    bool
+	windowEnd time.Time
+	repeated  uint64
+	timer     *time.Timer // closes the window, created by the first Write
+	err       error       // errors of summaries written when the window closed
+}
+
+type dedupKey struct {
+	level   zapcore.Level
+	logger  string
+	message string
+	hash    uint64
+}
+
+type DedupOption interface {
+	apply(*Dedup) error
+}
+
+type dedupOptionsFunc func(*Dedup) error
+
+func (f dedupOptionsFunc) apply(a *Dedup) error {
+	return f(a)
+}
+
+// DedupByPayload compares messages by a hash of the encoded payload.
+// That is only useful if the encoder does not output changing values like the time.
+func DedupByPayload() DedupOption {
+	return dedupOptionsFunc(func(a *Dedup) error {
+		a.keyFn = func(p []byte, _ zapcore.Entry) dedupKey {
+			h := fnv.New64a()
+			_, _ = h.Write(p)
+			return dedupKey{hash: h.Sum64()}
+		}
+		return nil
+	})
+}
+
+// DedupSummaryEncoder encodes the repetition summaries with enc.
+// Without an encoder, summaries are written as plain text lines.
+func DedupSummaryEncoder(enc zapcore.Encoder) DedupOption {
+	return dedupOptionsFunc(func(a *Dedup) error {
+		a.summaryEncoder = enc
+		return nil
+	})
+}
+
+// NewDedup creates a Dedup suppressing repetitions of a message within window.
+// The window starts with the first occurrence of a message and is not extended by its repetitions,
+// so a message repeated forever is forwarded once per window. A different message starts a new window.
+// Messages are compared by the key function, by default message, level and logger name; see DedupByPayload.
+func NewDedup(primary Appender, window time.Duration, options ...DedupOption) (a *Dedup, err error) {
+	if primary == nil {
+		return nil, errors.New("primary is required")
+	}
+	if window <= time.Duration(0) {
+		return nil, errors.New("window must be positive")
+	}
+	a = &Dedup{
+		primary: primary,
+		window:  window,
+		keyFn:   dedupKeyByMessage,
+		now:     time.Now,
+	}
+	for _, option := range options {
+		err = option.apply(a)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+func dedupKeyByMessage(_ []byte, ent zapcore.Entry) dedupKey {
+	return dedupKey{
+		level:   ent.Level,
+		logger:  ent.LoggerName,
+		message: ent.Message,
+	}
+}
+
+func (a *Dedup) Write(p []byte, ent zapcore.Entry) (n int, err error) {
+	return a.WriteFields(p, ent, Fields{})
+}
+
+// WriteFields forwards the fields of the first occurrence; the summaries carry no fields.
+func (a *Dedup) WriteFields(p []byte, ent zapcore.Entry, fields Fields) (n int, err error) {
+	key := a.keyFn(p, ent)
+	now := a.now()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.active && key == a.last && now.Before(a.windowEnd) {
+		a.repeated++
+		return len(p), nil
+	}
+
+	err = multierr.Append(a.takeErr(), a.writeSummary(now))
+	n, writeErr := writeWithFields(a.primary, p, ent, fields)
+	err = multierr.Append(err, writeErr)
+
+	a.last = key
+	a.lastEnt = ent
+	a.active = true
+	a.windowEnd = now.Add(a.window)
+	if a.timer == nil {
+		a.timer = time.AfterFunc(a.window, a.closeWindow)
+	} else {
+		a.timer.Reset(a.window)
+	}
+	return n, err
+}
+
+// closeWindow reports the repetitions once the window has passed.
+func (a *Dedup) closeWindow() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.active || a.now().Before(a.windowEnd) {
+		return // closed by Sync or the timer was reset for a newer window
+	}
+	a.err = multierr.Append(a.err, a.writeSummary(a.now()))
+	a.active = false
+}
+
+// takeErr returns and clears the errors of closed windows; it must be called with the lock held
+func (a *Dedup) takeErr() error {
+	err := a.err
+	a.err = nil
+	return err
+}
+
+func (a *Dedup) WantsFields() bool {
+	return WantsFields(a.primary)
+}
+
+// writeSummary must be called with the lock held
+func (a *Dedup) writeSummary(now time.Time) error {
+	if a.repeated == 0 {
+		return nil
+	}
+	ent := zapcore.Entry{
+		Level:      a.lastEnt.Level,
+		LoggerName: a.lastEnt.LoggerName,
+		Time:       now,
+		Message:    "last message repeated " + strconv.FormatUint(a.repeated, 10) + " times",
+	}
+	a.repeated = 0
+	return writeSynthetic(a.primary, a.summaryEncoder, ent)
+}
+
+// Sync reports pending repetitions, closes the current window and syncs the primary appender.
+func (a *Dedup) Sync() error {
+	a.mu.Lock()
+	err := multierr.Append(a.takeErr(), a.writeSummary(a.now()))
+	a.active = false
+	if a.timer != nil {
+		a.timer.Stop()
+	}
+	a.mu.Unlock()
+	return multierr.Append(err, a.primary.Sync())
+}
+
+func (a *Dedup) Synchronized() bool {
+	return true
+}
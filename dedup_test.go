@@ -0,0 +1,99 @@
+package zapappender
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/delixfe/zapappender/internal"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestDedup(t *testing.T) {
+	out := &internal.Buffer{}
+	a, err := NewDedup(NewWriter(out), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(0, 0)
+	a.now = func() time.Time { return now }
+
+	write := func(msg string) {
+		t.Helper()
+		if _, err := a.Write([]byte(msg+"\n"), zapcore.Entry{Message: msg}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("retrying")
+	write("retrying")
+	write("retrying")
+	write("giving up")
+	write("giving up")
+	now = now.Add(2 * time.Minute)
+	write("giving up")
+	write("giving up")
+	if err := a.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertLines(t, []string{
+		"retrying",
+		"last message repeated 2 times",
+		"giving up",
+		"last message repeated 1 times",
+		"giving up",
+		"last message repeated 1 times",
+	}, out.Lines(), "output")
+}
+
+func TestDedup_windowCloses_reportsRepetitions(t *testing.T) {
+	out := &internal.Buffer{}
+	a, err := NewDedup(NewWriter(out), time.Hour, DedupByPayload())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Sync()
+	now := time.Unix(0, 0)
+	a.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, err := a.Write([]byte("same\n"), zapcore.Entry{Message: "differs", Level: zapcore.Level(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a.closeWindow() // window has not passed yet
+	assertLines(t, []string{"same"}, out.Lines(), "before window end")
+
+	now = now.Add(time.Hour)
+	a.closeWindow()
+	assertLines(t, []string{
+		"same",
+		"last message repeated 2 times",
+	}, out.Lines(), "output")
+}
+
+func TestDedup_windowClose_errReturnedBySync(t *testing.T) {
+	errWrite := errors.New("write failed")
+	a, err := NewDedup(failingAppender{err: errWrite}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(0, 0)
+	a.now = func() time.Time { return now }
+
+	_, _ = a.Write([]byte("same\n"), zapcore.Entry{Message: "same"})
+	if _, err := a.Write([]byte("same\n"), zapcore.Entry{Message: "same"}); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Hour)
+	a.closeWindow()
+
+	if err := a.Sync(); !errors.Is(err, errWrite) {
+		t.Errorf("expected the summary error, got %v", err)
+	}
+	if err := a.Sync(); err != nil {
+		t.Errorf("expected the error to be reported once, got %v", err)
+	}
+}
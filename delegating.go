@@ -4,11 +4,16 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
-var _ SynchronizationAwareAppender = &Delegating{}
+var (
+	_ SynchronizationAwareAppender = &Delegating{}
+	_ FieldsAwareAppender          = &Delegating{}
+)
 
 // Delegating delegates Write and Sync to functions
 type Delegating struct {
-	WriteFn           func(p []byte, ent zapcore.Entry) (n int, err error)
+	WriteFn func(p []byte, ent zapcore.Entry) (n int, err error)
+	// WriteFieldsFn receives the structured fields if set, see FieldsAware.
+	WriteFieldsFn     func(p []byte, ent zapcore.Entry, fields Fields) (n int, err error)
 	SyncFn            func() error
 	SynchronizedValue bool
 }
@@ -29,6 +34,18 @@ func (a *Delegating) Write(p []byte, ent zapcore.Entry) (int, error) {
 	return writeFn(p, ent)
 }
 
+func (a *Delegating) WriteFields(p []byte, ent zapcore.Entry, fields Fields) (int, error) {
+	writeFieldsFn := a.WriteFieldsFn
+	if writeFieldsFn == nil {
+		return a.Write(p, ent)
+	}
+	return writeFieldsFn(p, ent, fields)
+}
+
+func (a *Delegating) WantsFields() bool {
+	return a.WriteFieldsFn != nil
+}
+
 func (a *Delegating) Sync() error {
 	syncFn := a.SyncFn
 	if syncFn == nil {
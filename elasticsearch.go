@@ -0,0 +1,163 @@
+package zapappender
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/delixfe/zapappender/internal/bufferpool"
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+// ElasticsearchConfig configures the Elasticsearch appender.
+type ElasticsearchConfig struct {
+	// IndexFn returns the index of the entry.
+	// The default is ElasticsearchDateIndex("logs-", "2006.01.02").
+	IndexFn func(ent zapcore.Entry) string
+	// Create uses the create action, as required for data streams, instead of index.
+	Create bool
+}
+
+// ElasticsearchDateIndex derives the index name from the prefix and the entry time in UTC formatted with layout.
+func ElasticsearchDateIndex(prefix, layout string) func(ent zapcore.Entry) string {
+	return func(ent zapcore.Entry) string {
+		return prefix + ent.Time.UTC().Format(layout)
+	}
+}
+
+// ElasticsearchRejectedError is returned if documents of a bulk request were rejected.
+type ElasticsearchRejectedError struct {
+	Rejected int
+	// Reason is the error of the first rejected document.
+	Reason string
+}
+
+func (e *ElasticsearchRejectedError) Error() string {
+	return fmt.Sprintf("elasticsearch rejected %d documents: %s", e.Rejected, e.Reason)
+}
+
+var _ SynchronizationAwareAppender = &Elasticsearch{}
+
+// Elasticsearch indexes messages using the Elasticsearch or OpenSearch bulk API.
+// The messages must be JSON encoded.
+//
+// The per document results of the bulk response are evaluated. Rejected documents are forwarded to the
+// appender set with HTTPOnFailureForwardTo, which acts as dead letter queue, and an
+// ElasticsearchRejectedError is returned.
+type Elasticsearch struct {
+	url       string
+	config    ElasticsearchConfig
+	transport *httpTransport
+	batch     *batcher
+}
+
+// NewElasticsearch creates an Elasticsearch appender sending to the cluster at url, e.g. http://localhost:9200.
+func NewElasticsearch(url string, config ElasticsearchConfig, options ...HTTPOption) (*Elasticsearch, error) {
+	if url == "" {
+		return nil, errors.New("url is required")
+	}
+	if config.IndexFn == nil {
+		config.IndexFn = ElasticsearchDateIndex("logs-", "2006.01.02")
+	}
+	transport, err := newHTTPTransport(options)
+	if err != nil {
+		return nil, err
+	}
+	a := &Elasticsearch{
+		url:       strings.TrimSuffix(url, "/") + "/_bulk",
+		config:    config,
+		transport: transport,
+	}
+	a.batch = transport.newBatcher(a.send)
+	return a, nil
+}
+
+func (a *Elasticsearch) Write(p []byte, ent zapcore.Entry) (n int, err error) {
+	buf := bufferpool.Get()
+	_, _ = buf.Write(p)
+	if err = a.batch.add("", buf, ent); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+type elasticsearchBulkResponse struct {
+	Errors bool                                     `json:"errors"`
+	Items  []map[string]elasticsearchBulkItemResult `json:"items"`
+}
+
+type elasticsearchBulkItemResult struct {
+	Status int             `json:"status"`
+	Error  json.RawMessage `json:"error"`
+}
+
+// send is called by the batcher, the calls are serialized.
+func (a *Elasticsearch) send(_ string, items []batchItem) error {
+	action := `{"index":{"_index":`
+	if a.config.Create {
+		action = `{"create":{"_index":`
+	}
+
+	body := bufferpool.Get()
+	defer body.Free()
+	for _, item := range items {
+		body.AppendString(action)
+		appendJSONString(body, a.config.IndexFn(item.ent))
+		body.AppendString("}}\n")
+		_, _ = body.Write(trimNewline(item.buf.Bytes()))
+		body.AppendByte('\n')
+	}
+
+	respBody, err := a.transport.post(a.url, "application/x-ndjson", body.Bytes(), nil)
+	if err != nil {
+		return multierr.Append(err, a.transport.forwardFailed(items))
+	}
+
+	var resp elasticsearchBulkResponse
+	if err = json.Unmarshal(respBody, &resp); err != nil {
+		err = fmt.Errorf("invalid bulk response: %w", err)
+		return multierr.Append(err, a.transport.forwardFailed(items))
+	}
+	if !resp.Errors {
+		return nil
+	}
+	if len(resp.Items) != len(items) {
+		err = fmt.Errorf("bulk response contains %d results for %d documents", len(resp.Items), len(items))
+		return multierr.Append(err, a.transport.forwardFailed(items))
+	}
+
+	rejectedErr := &ElasticsearchRejectedError{}
+	var rejected []batchItem
+	for i, result := range resp.Items {
+		for _, r := range result {
+			if r.Status >= 200 && r.Status < 300 {
+				continue
+			}
+			if rejectedErr.Rejected == 0 {
+				rejectedErr.Reason = string(r.Error)
+			}
+			rejectedErr.Rejected++
+			rejected = append(rejected, items[i])
+		}
+	}
+	if rejectedErr.Rejected == 0 {
+		return nil
+	}
+	return multierr.Append(rejectedErr, a.transport.forwardFailed(rejected))
+}
+
+// Sync sends the pending batch.
+func (a *Elasticsearch) Sync() error {
+	return a.batch.flush()
+}
+
+func (a *Elasticsearch) Synchronized() bool {
+	return true
+}
+
+// Shutdown stops the periodic flushing and sends the pending batch.
+func (a *Elasticsearch) Shutdown() error {
+	return a.batch.stop()
+}
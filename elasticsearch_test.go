@@ -0,0 +1,94 @@
+package zapappender_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/delixfe/zapappender"
+	"github.com/delixfe/zapappender/internal"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestElasticsearch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		expected := `{"create":{"_index":"logs-2020.09.13"}}` + "\n" + `{"msg":"ok"}` + "\n" +
+			`{"create":{"_index":"logs-2020.09.14"}}` + "\n" + `{"msg":"rejected"}` + "\n"
+		if string(body) != expected {
+			t.Errorf("unexpected body\n%s", body)
+		}
+		_, _ = w.Write([]byte(`{"took":3,"errors":true,"items":[
+			{"create":{"_index":"logs-2020.09.13","status":201}},
+			{"create":{"_index":"logs-2020.09.14","status":400,"error":{"type":"mapper_parsing_exception"}}}
+		]}`))
+	}))
+	defer server.Close()
+
+	deadLetter := &internal.Buffer{}
+	a, err := zapappender.NewElasticsearch(server.URL+"/", zapappender.ElasticsearchConfig{Create: true},
+		zapappender.HTTPOnFailureForwardTo(zapappender.NewWriter(deadLetter)),
+		zapappender.HTTPFlushInterval(time.Hour),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Shutdown()
+
+	ts := time.Date(2020, 9, 13, 23, 0, 0, 0, time.UTC)
+	_, _ = a.Write([]byte(`{"msg":"ok"}`+"\n"), zapcore.Entry{Time: ts})
+	_, _ = a.Write([]byte(`{"msg":"rejected"}`+"\n"), zapcore.Entry{Time: ts.Add(time.Hour)})
+
+	err = a.Sync()
+	var rejectedErr *zapappender.ElasticsearchRejectedError
+	if !errors.As(err, &rejectedErr) || rejectedErr.Rejected != 1 {
+		t.Fatalf("expected one rejected document, got %v", err)
+	}
+	if deadLetter.String() != `{"msg":"rejected"}`+"\n" {
+		t.Errorf("unexpected dead letters %q", deadLetter.String())
+	}
+}
+
+func TestElasticsearch_unknownOutcome_forwardsAll(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+	}{
+		{"item count mismatch", `{"took":3,"errors":true,"items":[{"create":{"status":201}}]}`},
+		{"invalid response", `<html>proxy error</html>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			deadLetter := &internal.Buffer{}
+			a, err := zapappender.NewElasticsearch(server.URL, zapappender.ElasticsearchConfig{},
+				zapappender.HTTPOnFailureForwardTo(zapappender.NewWriter(deadLetter)),
+				zapappender.HTTPFlushInterval(time.Hour),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer a.Shutdown()
+
+			_, _ = a.Write([]byte(`{"msg":"a"}`+"\n"), zapcore.Entry{Time: time.Now()})
+			_, _ = a.Write([]byte(`{"msg":"b"}`+"\n"), zapcore.Entry{Time: time.Now()})
+
+			if err := a.Sync(); err == nil {
+				t.Error("expected an error")
+			}
+			if deadLetter.String() != `{"msg":"a"}`+"\n"+`{"msg":"b"}`+"\n" {
+				t.Errorf("unexpected dead letters %q", deadLetter.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package zapappender
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"unicode"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+const encryptedRecordVersion = "v1"
+
+// EncryptionKeyProvider provides the keys to seal and open encrypted records.
+// Any AEAD can be used, e.g. AES-GCM via NewAESGCM or ChaCha20-Poly1305 from golang.org/x/crypto.
+type EncryptionKeyProvider interface {
+	// CurrentKey returns the id and AEAD used to seal new records.
+	CurrentKey() (keyID string, aead cipher.AEAD, err error)
+	// Key returns the AEAD for keyID to open records.
+	Key(keyID string) (cipher.AEAD, error)
+}
+
+// NewAESGCM creates an AES-GCM AEAD from a 16, 24 or 32 byte key.
+func NewAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+var _ EncryptionKeyProvider = &EncryptionKeyRing{}
+
+// EncryptionKeyRing is an EncryptionKeyProvider holding keys by id.
+// Keys can be added and the current key switched at runtime to rotate keys.
+type EncryptionKeyRing struct {
+	mu      sync.RWMutex
+	keys    map[string]cipher.AEAD
+	current string
+}
+
+// NewEncryptionKeyRing creates an EncryptionKeyRing sealing with aead under keyID.
+func NewEncryptionKeyRing(keyID string, aead cipher.AEAD) (*EncryptionKeyRing, error) {
+	r := &EncryptionKeyRing{keys: make(map[string]cipher.AEAD)}
+	if err := r.Add(keyID, aead); err != nil {
+		return nil, err
+	}
+	r.current = keyID
+	return r, nil
+}
+
+// Add adds a key to open records with. Key ids must not contain colons or whitespace.
+func (r *EncryptionKeyRing) Add(keyID string, aead cipher.AEAD) error {
+	if err := validateEncryptionKeyID(keyID); err != nil {
+		return err
+	}
+	if aead == nil {
+		return errors.New("aead must not be nil")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys[keyID] = aead
+	return nil
+}
+
+// SetCurrent switches the key used to seal new records to a previously added key.
+func (r *EncryptionKeyRing) SetCurrent(keyID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.keys[keyID]; !ok {
+		return fmt.Errorf("unknown key id %q", keyID)
+	}
+	r.current = keyID
+	return nil
+}
+
+func (r *EncryptionKeyRing) CurrentKey() (string, cipher.AEAD, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current, r.keys[r.current], nil
+}
+
+func (r *EncryptionKeyRing) Key(keyID string) (cipher.AEAD, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	aead, ok := r.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", keyID)
+	}
+	return aead, nil
+}
+
+func validateEncryptionKeyID(keyID string) error {
+	if keyID == "" || strings.IndexFunc(keyID, func(r rune) bool { return r == ':' || unicode.IsSpace(r) }) != -1 {
+		return fmt.Errorf("invalid key id %q", keyID)
+	}
+	return nil
+}
+
+// NewEncryptingEnvelopingFn creates an EnvelopingFn sealing each record with the current key of keys.
+//
+// Every record becomes one line:
+//
+//	v1:<key id>:<base64 of nonce and ciphertext>
+//
+// The version and key id are authenticated as additional data. The plaintext is the complete encoded message
+// including its line ending. Use NewDecryptingReader to read the log.
+// Key ids containing colons or whitespace are rejected with an error.
+func NewEncryptingEnvelopingFn(keys EncryptionKeyProvider) EnvelopingFn {
+	return func(p []byte, ent zapcore.Entry, output *buffer.Buffer) error {
+		keyID, aead, err := keys.CurrentKey()
+		if err != nil {
+			return err
+		}
+		if err = validateEncryptionKeyID(keyID); err != nil {
+			return err
+		}
+		if aead == nil {
+			return errors.New("aead must not be nil")
+		}
+		prefix := encryptedRecordVersion + ":" + keyID
+		sealed := make([]byte, aead.NonceSize(), aead.NonceSize()+len(p)+aead.Overhead())
+		if _, err = io.ReadFull(rand.Reader, sealed); err != nil {
+			return err
+		}
+		sealed = aead.Seal(sealed, sealed, p, []byte(prefix))
+
+		encoded := make([]byte, base64.StdEncoding.EncodedLen(len(sealed)))
+		base64.StdEncoding.Encode(encoded, sealed)
+		output.AppendString(prefix)
+		output.AppendByte(':')
+		_, _ = output.Write(encoded)
+		output.AppendByte('\n')
+		return nil
+	}
+}
+
+// NewEncrypting creates an Enveloping sealing each record as described by NewEncryptingEnvelopingFn.
+func NewEncrypting(inner Appender, keys EncryptionKeyProvider) (*Enveloping, error) {
+	if keys == nil {
+		return nil, errors.New("keys are required")
+	}
+	return NewEnveloping(inner, NewEncryptingEnvelopingFn(keys)), nil
+}
+
+// NewDecryptingReader returns a reader with the plaintext of the records in r as written by NewEncrypting.
+// Empty lines are skipped. Reading fails on the first record that cannot be opened.
+func NewDecryptingReader(r io.Reader, keys EncryptionKeyProvider) io.Reader {
+	return &decryptingReader{
+		reader: bufio.NewReader(r),
+		keys:   keys,
+	}
+}
+
+type decryptingReader struct {
+	reader  *bufio.Reader
+	keys    EncryptionKeyProvider
+	pending []byte
+	line    int
+	err     error
+}
+
+func (d *decryptingReader) Read(p []byte) (n int, err error) {
+	for len(d.pending) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		d.pending, d.err = d.next()
+	}
+	n = copy(p, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
+
+// next opens the next record.
+func (d *decryptingReader) next() ([]byte, error) {
+	line, err := d.reader.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(line) == 0 && err == io.EOF {
+		return nil, io.EOF
+	}
+	d.line++
+	line = trimNewline(line)
+	if len(line) == 0 {
+		return nil, err
+	}
+	plaintext, openErr := d.open(line)
+	if openErr != nil {
+		return nil, fmt.Errorf("line %d: %w", d.line, openErr)
+	}
+	return plaintext, err
+}
+
+func (d *decryptingReader) open(line []byte) ([]byte, error) {
+	versionEnd := bytes.IndexByte(line, ':')
+	if versionEnd == -1 || string(line[:versionEnd]) != encryptedRecordVersion {
+		return nil, errors.New("unsupported record format")
+	}
+	keyEnd := bytes.IndexByte(line[versionEnd+1:], ':')
+	if keyEnd == -1 {
+		return nil, errors.New("missing key id")
+	}
+	keyEnd += versionEnd + 1
+	aead, err := d.keys.Key(string(line[versionEnd+1 : keyEnd]))
+	if err != nil {
+		return nil, err
+	}
+	if aead == nil {
+		return nil, errors.New("aead must not be nil")
+	}
+	sealed := make([]byte, base64.StdEncoding.DecodedLen(len(line)-keyEnd-1))
+	n, err := base64.StdEncoding.Decode(sealed, line[keyEnd+1:])
+	if err != nil {
+		return nil, err
+	}
+	sealed = sealed[:n]
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("record too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(ciphertext[:0], nonce, ciphertext, line[:keyEnd])
+}
@@ -0,0 +1,119 @@
+package zapappender_test
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/delixfe/zapappender"
+	"github.com/delixfe/zapappender/internal"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestEncrypting_roundTripWithRotation(t *testing.T) {
+	key1, _ := zapappender.NewAESGCM(bytes.Repeat([]byte{1}, 32))
+	key2, _ := zapappender.NewAESGCM(bytes.Repeat([]byte{2}, 16))
+	keys, err := zapappender.NewEncryptionKeyRing("k1", key1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &internal.Buffer{}
+	a, err := zapappender.NewEncrypting(zapappender.NewWriter(out), keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = a.Write([]byte("first secret\n"), zapcore.Entry{})
+	if err := keys.Add("k2", key2); err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.SetCurrent("k2"); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = a.Write([]byte("second secret\n"), zapcore.Entry{})
+
+	lines := out.Lines()
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "v1:k1:") || !strings.HasPrefix(lines[1], "v1:k2:") {
+		t.Fatalf("unexpected records %q", lines)
+	}
+	if strings.Contains(out.String(), "secret") {
+		t.Fatal("plaintext leaked")
+	}
+
+	plaintext, err := io.ReadAll(zapappender.NewDecryptingReader(strings.NewReader(out.String()), keys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != "first secret\nsecond secret\n" {
+		t.Errorf("unexpected plaintext %q", plaintext)
+	}
+}
+
+func TestDecryptingReader_tampered_fails(t *testing.T) {
+	key, _ := zapappender.NewAESGCM(bytes.Repeat([]byte{1}, 32))
+	keys, _ := zapappender.NewEncryptionKeyRing("k1", key)
+	other, _ := zapappender.NewEncryptionKeyRing("k2", key)
+	out := &internal.Buffer{}
+	a, _ := zapappender.NewEncrypting(zapappender.NewWriter(out), keys)
+	_, _ = a.Write([]byte("secret\n"), zapcore.Entry{})
+
+	// the key id is authenticated, relabeling the record must fail
+	relabeled := strings.Replace(out.String(), "v1:k1:", "v1:k2:", 1)
+	_, err := io.ReadAll(zapappender.NewDecryptingReader(strings.NewReader(relabeled), other))
+	if err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("expected an error for line 1, got %v", err)
+	}
+
+	_, err = io.ReadAll(zapappender.NewDecryptingReader(strings.NewReader("v1:unknown:AAAA\n"), keys))
+	if err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+}
+
+type staticKeyProvider struct {
+	keyID string
+	aead  cipher.AEAD
+}
+
+func (p staticKeyProvider) CurrentKey() (string, cipher.AEAD, error) {
+	return p.keyID, p.aead, nil
+}
+
+func (p staticKeyProvider) Key(string) (cipher.AEAD, error) {
+	return p.aead, nil
+}
+
+func TestEncrypting_invalidKeyID_returnsErr(t *testing.T) {
+	key, _ := zapappender.NewAESGCM(bytes.Repeat([]byte{1}, 32))
+	for _, keyID := range []string{"", "k:1", "k 1", "k\n1"} {
+		out := &internal.Buffer{}
+		a, err := zapappender.NewEncrypting(zapappender.NewWriter(out), staticKeyProvider{keyID: keyID, aead: key})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := a.Write([]byte("secret\n"), zapcore.Entry{}); err == nil {
+			t.Errorf("key id %q: expected an error", keyID)
+		}
+		if out.String() != "" {
+			t.Errorf("key id %q: unexpected output %q", keyID, out.String())
+		}
+	}
+}
+
+func TestEncrypting_nilAEAD_returnsErr(t *testing.T) {
+	out := &internal.Buffer{}
+	a, err := zapappender.NewEncrypting(zapappender.NewWriter(out), staticKeyProvider{keyID: "k1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Write([]byte("secret\n"), zapcore.Entry{}); err == nil {
+		t.Error("expected an error")
+	}
+
+	_, err = io.ReadAll(zapappender.NewDecryptingReader(strings.NewReader("v1:k1:AAAA\n"), staticKeyProvider{keyID: "k1"}))
+	if err == nil {
+		t.Error("expected an error")
+	}
+}
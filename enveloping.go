@@ -46,16 +46,24 @@ func NewEnvelopingPreSuffix(inner Appender, prefix, suffix string) *Enveloping {
 }
 
 func (a *Enveloping) Write(p []byte, ent zapcore.Entry) (n int, err error) {
+	return a.WriteFields(p, ent, Fields{})
+}
+
+func (a *Enveloping) WriteFields(p []byte, ent zapcore.Entry, fields Fields) (n int, err error) {
 	buf := bufferpool.Get()
 	defer buf.Free()
 	err = a.envFn(p, ent, buf)
 	if err != nil {
 		return
 	}
-	n, err = a.primary.Write(buf.Bytes(), ent)
+	n, err = writeWithFields(a.primary, buf.Bytes(), ent, fields)
 	return
 }
 
+func (a *Enveloping) WantsFields() bool {
+	return WantsFields(a.primary)
+}
+
 func (a *Enveloping) Sync() error {
 	return a.primary.Sync()
 }
@@ -22,12 +22,16 @@ func NewFallback(primary, secondary Appender) *Fallback {
 }
 
 func (a *Fallback) Write(p []byte, ent zapcore.Entry) (n int, err error) {
+	return a.WriteFields(p, ent, Fields{})
+}
+
+func (a *Fallback) WriteFields(p []byte, ent zapcore.Entry, fields Fields) (n int, err error) {
 
-	n, primErr := a.primary.Write(p, ent)
+	n, primErr := writeWithFields(a.primary, p, ent, fields)
 	if primErr == nil {
 		return n, nil
 	}
-	n, fallErr := a.secondary.Write(p, ent)
+	n, fallErr := writeWithFields(a.secondary, p, ent, fields)
 	if fallErr == nil {
 		return n, nil
 	}
@@ -37,6 +41,10 @@ func (a *Fallback) Write(p []byte, ent zapcore.Entry) (n int, err error) {
 
 }
 
+func (a *Fallback) WantsFields() bool {
+	return WantsFields(a.primary) || WantsFields(a.secondary)
+}
+
 func (a *Fallback) Sync() error {
 	return multierr.Append(a.primary.Sync(), a.secondary.Sync())
 }
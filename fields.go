@@ -0,0 +1,229 @@
+package zapappender
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// FieldsAware is implemented by appenders able to process the structured fields of the entries.
+//
+// AppenderCore calls WriteFields instead of Write if WantsFields returns true.
+// Collecting the fields costs allocations for every entry, appenders not needing them keep the Write path.
+// Composite appenders like Async or Router implement FieldsAware and want fields if one of their
+// appenders does.
+type FieldsAware interface {
+	// WantsFields returns true if WriteFields should be called instead of Write.
+	WantsFields() bool
+	// WriteFields is Write with the fields of the entry. fields may be retained.
+	WriteFields(p []byte, ent zapcore.Entry, fields Fields) (n int, err error)
+}
+
+type FieldsAwareAppender interface {
+	Appender
+	FieldsAware
+}
+
+// WantsFields returns true if a is FieldsAware and wants fields.
+func WantsFields(a interface{}) bool {
+	if a, ok := a.(FieldsAware); ok && a.WantsFields() {
+		return true
+	}
+	return false
+}
+
+// writeWithFields forwards to WriteFields if a wants fields and to Write otherwise.
+func writeWithFields(a Appender, p []byte, ent zapcore.Entry, fields Fields) (n int, err error) {
+	if fa, ok := a.(FieldsAware); ok && fa.WantsFields() {
+		return fa.WriteFields(p, ent, fields)
+	}
+	return a.Write(p, ent)
+}
+
+// Fields is an immutable snapshot of the structured fields of an entry, including the fields added with With.
+//
+// The values are those produced by zapcore.MapObjectEncoder: bool, the numeric types, string, []byte,
+// time.Time, time.Duration, []interface{} for arrays and map[string]interface{} for objects and namespaces.
+// Reflected values are converted to their JSON representation.
+// All values are copied, so a snapshot is safe to retain, e.g. in Async. Returned maps and slices must not be modified.
+type Fields struct {
+	// context holds the fields added with With, shared by all entries of the logger
+	context fieldsContext
+	// m holds the fields of the entry, they belong into the innermost namespace of the context
+	m map[string]interface{}
+}
+
+// Len returns the number of top level fields.
+func (f Fields) Len() int {
+	if len(f.context.namespace) > 0 {
+		return len(f.context.m)
+	}
+	n := len(f.context.m)
+	for key := range f.m {
+		if _, ok := f.context.m[key]; !ok {
+			n++
+		}
+	}
+	return n
+}
+
+// Get returns the value of the top level field key.
+func (f Fields) Get(key string) (value interface{}, ok bool) {
+	if len(f.context.namespace) > 0 {
+		if key == f.context.namespace[0] && len(f.m) > 0 {
+			return f.merged()[key], true
+		}
+		value, ok = f.context.m[key]
+		return
+	}
+	if value, ok = f.m[key]; ok {
+		return
+	}
+	value, ok = f.context.m[key]
+	return
+}
+
+// Keys returns the sorted keys of the top level fields.
+func (f Fields) Keys() []string {
+	keys := make([]string, 0, f.Len())
+	for key := range f.context.m {
+		keys = append(keys, key)
+	}
+	if len(f.context.namespace) == 0 {
+		for key := range f.m {
+			if _, ok := f.context.m[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// String returns the value of the top level field key formatted with fmt.Sprint.
+// Missing fields are returned as empty string.
+func (f Fields) String(key string) string {
+	value, ok := f.Get(key)
+	if !ok {
+		return ""
+	}
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
+
+// merged returns the fields of the context and the entry in one map.
+func (f Fields) merged() map[string]interface{} {
+	if len(f.m) == 0 {
+		return f.context.m
+	}
+	if len(f.context.m) == 0 {
+		return f.m
+	}
+	return f.context.merge(f.m)
+}
+
+// fieldsContext holds the fields added with With.
+type fieldsContext struct {
+	m map[string]interface{} // immutable
+	// namespace lists the namespaces opened by the fields, new fields are added to the innermost
+	namespace []string
+}
+
+// with adds fields to enc and returns a new context with the fields added.
+// The fields are encoded and recorded in one pass, so marshalers are only called once.
+func (c fieldsContext) with(enc zapcore.ObjectEncoder, fields []zapcore.Field) fieldsContext {
+	if len(fields) == 0 {
+		return c
+	}
+	tee := newFieldsTee(enc)
+	tee.addFields(fields)
+
+	namespace := c.namespace
+	if len(*tee.namespaces) > 0 {
+		namespace = append(append([]string(nil), c.namespace...), *tee.namespaces...)
+	}
+	return fieldsContext{m: c.merge(copyFieldMap(tee.rec.Fields)), namespace: namespace}
+}
+
+// merge returns a copy of the context fields with values added to the innermost namespace.
+// Only the maps along the namespace path are copied.
+func (c fieldsContext) merge(values map[string]interface{}) map[string]interface{} {
+	root := copyFieldMapShallow(c.m, len(values))
+	inner := root
+	for _, ns := range c.namespace {
+		nested, _ := inner[ns].(map[string]interface{})
+		nested = copyFieldMapShallow(nested, len(values))
+		inner[ns] = nested
+		inner = nested
+	}
+	for key, value := range values {
+		inner[key] = value
+	}
+	return root
+}
+
+// encodeEntry encodes the entry with enc and returns the fields snapshot recorded in the same pass.
+func (c fieldsContext) encodeEntry(enc zapcore.Encoder, ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, Fields, error) {
+	if len(fields) == 0 {
+		buf, err := enc.EncodeEntry(ent, nil)
+		return buf, Fields{context: c}, err
+	}
+	tee := &fieldsTee{rec: zapcore.NewMapObjectEncoder()}
+	field, recordSkipped := tee.inline(fields)
+	buf, err := enc.EncodeEntry(ent, []zapcore.Field{field})
+	recordSkipped()
+	return buf, Fields{context: c, m: copyFieldMap(tee.rec.Fields)}, err
+}
+
+func copyFieldMapShallow(m map[string]interface{}, extra int) map[string]interface{} {
+	c := make(map[string]interface{}, len(m)+extra)
+	for key, value := range m {
+		c[key] = value
+	}
+	return c
+}
+
+func copyFieldMap(m map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		c[key] = copyFieldValue(value)
+	}
+	return c
+}
+
+// copyFieldValue deep copies a value produced by zapcore.MapObjectEncoder.
+func copyFieldValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, bool, string, time.Time, time.Duration,
+		int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr,
+		float32, float64, complex64, complex128:
+		return v
+	case []byte:
+		return append([]byte(nil), v...)
+	case map[string]interface{}:
+		return copyFieldMap(v)
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i := range v {
+			c[i] = copyFieldValue(v[i])
+		}
+		return c
+	default:
+		// reflected values might be mutated after logging, keep their JSON representation
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		var decoded interface{}
+		if err = json.Unmarshal(data, &decoded); err != nil {
+			return string(data)
+		}
+		return decoded
+	}
+}
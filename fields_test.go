@@ -0,0 +1,234 @@
+package zapappender_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/delixfe/zapappender"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type fieldsSpy struct {
+	mu     sync.Mutex
+	wants  bool
+	writes int
+	fields []zapappender.Fields
+}
+
+func (s *fieldsSpy) Write(p []byte, ent zapcore.Entry) (n int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes++
+	return len(p), nil
+}
+
+func (s *fieldsSpy) WriteFields(p []byte, ent zapcore.Entry, fields zapappender.Fields) (n int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fields = append(s.fields, fields)
+	return len(p), nil
+}
+
+func (s *fieldsSpy) WantsFields() bool {
+	return s.wants
+}
+
+func (s *fieldsSpy) Sync() error {
+	return nil
+}
+
+func (s *fieldsSpy) received() []zapappender.Fields {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]zapappender.Fields(nil), s.fields...)
+}
+
+type mutableUser struct {
+	Name string
+}
+
+func TestAppenderCore_passesFieldsSnapshot(t *testing.T) {
+	spy := &fieldsSpy{wants: true}
+	var a zapappender.Appender = zapappender.NewEnvelopingPreSuffix(spy, "", "")
+	a, _ = zapappender.NewRouter(nil, a)
+	logger := zap.New(zapappender.NewAppenderCore(zapcore.NewJSONEncoder(encoderConfig), a, zapcore.DebugLevel))
+
+	user := &mutableUser{Name: "bob"}
+	logger.With(zap.String("app", "shop"), zap.Namespace("req")).
+		With(zap.Int("id", 7)).
+		Info("hello", zap.Any("user", user), zap.Strings("tags", []string{"a", "b"}))
+	user.Name = "mallory"
+
+	received := spy.received()
+	if len(received) != 1 {
+		t.Fatalf("expected one WriteFields call, got %d", len(received))
+	}
+	fields := received[0]
+	if fields.String("app") != "shop" {
+		t.Errorf("expected app from With, got %q", fields.String("app"))
+	}
+	req, _ := fields.Get("req")
+	reqFields, ok := req.(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected namespace req to be a map, got %#v", req)
+	}
+	if reqFields["id"] != int64(7) {
+		t.Errorf("expected id within the namespace, got %#v", reqFields["id"])
+	}
+	userValue, _ := reqFields["user"].(map[string]interface{})
+	if userValue["Name"] != "bob" {
+		t.Errorf("expected a copy of the reflected user, got %#v", reqFields["user"])
+	}
+	if tags, _ := reqFields["tags"].([]interface{}); len(tags) != 2 || tags[0] != "a" {
+		t.Errorf("unexpected tags %#v", reqFields["tags"])
+	}
+}
+
+func TestAppenderCore_withoutWantsFields_usesWrite(t *testing.T) {
+	spy := &fieldsSpy{wants: false}
+	logger := zap.New(zapappender.NewAppenderCore(zapcore.NewJSONEncoder(encoderConfig), spy, zapcore.DebugLevel))
+	logger.With(zap.String("app", "shop")).Info("hello", zap.Int("id", 7))
+
+	if spy.writes != 1 || len(spy.received()) != 0 {
+		t.Errorf("expected one Write call, got %d writes and %d WriteFields calls", spy.writes, len(spy.received()))
+	}
+}
+
+func TestAsync_retainsFields(t *testing.T) {
+	spy := &fieldsSpy{wants: true}
+	async, err := zapappender.NewAsync(spy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer async.Shutdown(context.Background())
+	logger := zap.New(zapappender.NewAppenderCore(zapcore.NewJSONEncoder(encoderConfig), async, zapcore.DebugLevel))
+
+	logger.Info("hello", zap.Duration("took", time.Second))
+	_ = async.Sync()
+
+	received := spy.received()
+	if len(received) != 1 {
+		t.Fatalf("expected one WriteFields call, got %d", len(received))
+	}
+	if took, _ := received[0].Get("took"); took != time.Second {
+		t.Errorf("expected took=1s, got %#v", took)
+	}
+}
+
+func TestFingersCrossed_keepsFieldsOfBufferedEntries(t *testing.T) {
+	spy := &fieldsSpy{wants: true}
+	recorder, err := zapappender.NewFlightRecorder(spy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fingersCrossed, err := zapappender.NewFingersCrossed(recorder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := zap.New(zapappender.NewAppenderCore(zapcore.NewJSONEncoder(encoderConfig), fingersCrossed, zapcore.DebugLevel))
+
+	logger.Info("buffered", zap.Int("step", 1))
+	logger.Error("trigger", zap.Int("step", 2))
+
+	received := spy.received()
+	if len(received) != 2 {
+		t.Fatalf("expected two WriteFields calls, got %d", len(received))
+	}
+	for i, fields := range received {
+		if step, _ := fields.Get("step"); step != int64(i+1) {
+			t.Errorf("entry %d: expected step=%d, got %#v", i, i+1, step)
+		}
+	}
+	records := recorder.Snapshot()
+	if len(records) != 2 || records[0].Fields.String("step") != "1" {
+		t.Errorf("expected the recorded fields, got %+v", records)
+	}
+}
+
+func TestRedacting_redactsFields(t *testing.T) {
+	spy := &fieldsSpy{wants: true}
+	a, err := zapappender.NewRedacting(spy, []zapappender.RedactMatchFn{zapappender.RedactEmail(), zapappender.RedactCardNumber()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := zap.New(zapappender.NewAppenderCore(zapcore.NewJSONEncoder(encoderConfig), a, zapcore.DebugLevel))
+
+	logger.Info("signup", zap.String("email", "bob@example.com"), zap.Int64("card", 4111111111111111),
+		zap.Strings("cc", []string{"alice@example.com"}), zap.Int("age", 42))
+
+	received := spy.received()
+	if len(received) != 1 {
+		t.Fatalf("expected one WriteFields call, got %d", len(received))
+	}
+	fields := received[0]
+	if fields.String("email") != "[REDACTED]" || fields.String("card") != "[REDACTED]" {
+		t.Errorf("expected redacted fields, got %v", fields)
+	}
+	if cc, _ := fields.Get("cc"); cc.([]interface{})[0] != "[REDACTED]" {
+		t.Errorf("expected redacted array values, got %#v", cc)
+	}
+	if age, _ := fields.Get("age"); age != int64(42) {
+		t.Errorf("expected unchanged age, got %#v", age)
+	}
+}
+
+type countingMarshaler struct {
+	calls *int
+}
+
+func (m countingMarshaler) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	*m.calls++
+	enc.AddString("name", "bob")
+	return enc.AddArray("roles", zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+		enc.AppendString("admin")
+		return enc.AppendObject(zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+			enc.AddInt64("level", 2)
+			return nil
+		}))
+	}))
+}
+
+func TestAppenderCore_marshalsFieldsOnce(t *testing.T) {
+	var (
+		payload string
+		fields  zapappender.Fields
+	)
+	a := &zapappender.Delegating{
+		WriteFieldsFn: func(p []byte, ent zapcore.Entry, f zapappender.Fields) (int, error) {
+			payload, fields = string(p), f
+			return len(p), nil
+		},
+	}
+	logger := zap.New(zapappender.NewAppenderCore(zapcore.NewJSONEncoder(encoderConfig), a, zapcore.DebugLevel))
+
+	withCalls, writeCalls := 0, 0
+	logger.With(zap.Object("user", countingMarshaler{calls: &withCalls})).
+		Info("hello", zap.Object("actor", countingMarshaler{calls: &writeCalls}))
+
+	if withCalls != 1 || writeCalls != 1 {
+		t.Errorf("expected each marshaler to be called once, got %d for With and %d for Write", withCalls, writeCalls)
+	}
+	expected := `{"level":"info","msg":"hello","user":{"name":"bob","roles":["admin",{"level":2}]},` +
+		`"actor":{"name":"bob","roles":["admin",{"level":2}]}}` + "\n"
+	if payload != expected {
+		t.Errorf("unexpected payload\n%s", payload)
+	}
+
+	if keys := fields.Keys(); len(keys) != 2 || keys[0] != "actor" || keys[1] != "user" {
+		t.Errorf("unexpected keys %v", keys)
+	}
+	for _, key := range []string{"user", "actor"} {
+		value, _ := fields.Get(key)
+		object, _ := value.(map[string]interface{})
+		roles, _ := object["roles"].([]interface{})
+		if object["name"] != "bob" || len(roles) != 2 || roles[0] != "admin" {
+			t.Fatalf("%s: unexpected value %#v", key, value)
+		}
+		if nested, _ := roles[1].(map[string]interface{}); nested["level"] != int64(2) {
+			t.Errorf("%s: unexpected nested object %#v", key, roles[1])
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package zapappender
+
+import (
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// fieldsTee forwards the fields to an encoder and records them into a MapObjectEncoder at the same time.
+// Marshalers are called once, the nested values are recorded while the encoder encodes them.
+type fieldsTee struct {
+	zapcore.ObjectEncoder
+	rec *zapcore.MapObjectEncoder
+	// namespaces lists the namespaces opened on the top level, nil for nested objects
+	namespaces *[]string
+}
+
+func newFieldsTee(enc zapcore.ObjectEncoder) *fieldsTee {
+	return &fieldsTee{
+		ObjectEncoder: enc,
+		rec:           zapcore.NewMapObjectEncoder(),
+		namespaces:    new([]string),
+	}
+}
+
+// addFields adds fields to the encoder and the recorder.
+func (t *fieldsTee) addFields(fields []zapcore.Field) {
+	for i := range fields {
+		fields[i].AddTo(t)
+	}
+}
+
+// inline returns a field adding fields to the encoder it is added to and to the recorder.
+// If the encoder does not add the field, only the recorder receives the fields.
+func (t *fieldsTee) inline(fields []zapcore.Field) (field zapcore.Field, recordSkipped func()) {
+	added := false
+	field = zapcore.Field{
+		Type: zapcore.InlineMarshalerType,
+		Interface: zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+			added = true
+			t.ObjectEncoder = enc
+			t.addFields(fields)
+			return nil
+		}),
+	}
+	return field, func() {
+		if !added {
+			t.ObjectEncoder = zapcore.NewMapObjectEncoder()
+			t.addFields(fields)
+		}
+	}
+}
+
+func (t *fieldsTee) AddArray(key string, marshaler zapcore.ArrayMarshaler) error {
+	rec := &fieldsArrayTee{}
+	err := t.ObjectEncoder.AddArray(key, zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+		rec.ArrayEncoder = enc
+		return marshaler.MarshalLogArray(rec)
+	}))
+	if rec.ArrayEncoder == nil {
+		// the encoder skipped the array
+		rec.values, err = recordArray(marshaler)
+	}
+	_ = t.rec.AddReflected(key, rec.values)
+	return err
+}
+
+func (t *fieldsTee) AddObject(key string, marshaler zapcore.ObjectMarshaler) error {
+	rec := &fieldsTee{rec: zapcore.NewMapObjectEncoder()}
+	err := t.ObjectEncoder.AddObject(key, zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		rec.ObjectEncoder = enc
+		return marshaler.MarshalLogObject(rec)
+	}))
+	if rec.ObjectEncoder == nil {
+		// the encoder skipped the object
+		err = marshaler.MarshalLogObject(rec.rec)
+	}
+	_ = t.rec.AddReflected(key, rec.rec.Fields)
+	return err
+}
+
+func (t *fieldsTee) AddBinary(key string, value []byte) {
+	t.ObjectEncoder.AddBinary(key, value)
+	t.rec.AddBinary(key, value)
+}
+
+func (t *fieldsTee) AddByteString(key string, value []byte) {
+	t.ObjectEncoder.AddByteString(key, value)
+	t.rec.AddByteString(key, value)
+}
+
+func (t *fieldsTee) AddBool(key string, value bool) {
+	t.ObjectEncoder.AddBool(key, value)
+	t.rec.AddBool(key, value)
+}
+
+func (t *fieldsTee) AddComplex128(key string, value complex128) {
+	t.ObjectEncoder.AddComplex128(key, value)
+	t.rec.AddComplex128(key, value)
+}
+
+func (t *fieldsTee) AddComplex64(key string, value complex64) {
+	t.ObjectEncoder.AddComplex64(key, value)
+	t.rec.AddComplex64(key, value)
+}
+
+func (t *fieldsTee) AddDuration(key string, value time.Duration) {
+	t.ObjectEncoder.AddDuration(key, value)
+	t.rec.AddDuration(key, value)
+}
+
+func (t *fieldsTee) AddFloat64(key string, value float64) {
+	t.ObjectEncoder.AddFloat64(key, value)
+	t.rec.AddFloat64(key, value)
+}
+
+func (t *fieldsTee) AddFloat32(key string, value float32) {
+	t.ObjectEncoder.AddFloat32(key, value)
+	t.rec.AddFloat32(key, value)
+}
+
+func (t *fieldsTee) AddInt(key string, value int) {
+	t.ObjectEncoder.AddInt(key, value)
+	t.rec.AddInt(key, value)
+}
+
+func (t *fieldsTee) AddInt64(key string, value int64) {
+	t.ObjectEncoder.AddInt64(key, value)
+	t.rec.AddInt64(key, value)
+}
+
+func (t *fieldsTee) AddInt32(key string, value int32) {
+	t.ObjectEncoder.AddInt32(key, value)
+	t.rec.AddInt32(key, value)
+}
+
+func (t *fieldsTee) AddInt16(key string, value int16) {
+	t.ObjectEncoder.AddInt16(key, value)
+	t.rec.AddInt16(key, value)
+}
+
+func (t *fieldsTee) AddInt8(key string, value int8) {
+	t.ObjectEncoder.AddInt8(key, value)
+	t.rec.AddInt8(key, value)
+}
+
+func (t *fieldsTee) AddString(key, value string) {
+	t.ObjectEncoder.AddString(key, value)
+	t.rec.AddString(key, value)
+}
+
+func (t *fieldsTee) AddTime(key string, value time.Time) {
+	t.ObjectEncoder.AddTime(key, value)
+	t.rec.AddTime(key, value)
+}
+
+func (t *fieldsTee) AddUint(key string, value uint) {
+	t.ObjectEncoder.AddUint(key, value)
+	t.rec.AddUint(key, value)
+}
+
+func (t *fieldsTee) AddUint64(key string, value uint64) {
+	t.ObjectEncoder.AddUint64(key, value)
+	t.rec.AddUint64(key, value)
+}
+
+func (t *fieldsTee) AddUint32(key string, value uint32) {
+	t.ObjectEncoder.AddUint32(key, value)
+	t.rec.AddUint32(key, value)
+}
+
+func (t *fieldsTee) AddUint16(key string, value uint16) {
+	t.ObjectEncoder.AddUint16(key, value)
+	t.rec.AddUint16(key, value)
+}
+
+func (t *fieldsTee) AddUint8(key string, value uint8) {
+	t.ObjectEncoder.AddUint8(key, value)
+	t.rec.AddUint8(key, value)
+}
+
+func (t *fieldsTee) AddUintptr(key string, value uintptr) {
+	t.ObjectEncoder.AddUintptr(key, value)
+	t.rec.AddUintptr(key, value)
+}
+
+// AddReflected records the value itself, it is converted when the snapshot is copied.
+func (t *fieldsTee) AddReflected(key string, value interface{}) error {
+	_ = t.rec.AddReflected(key, value)
+	return t.ObjectEncoder.AddReflected(key, value)
+}
+
+func (t *fieldsTee) OpenNamespace(key string) {
+	t.ObjectEncoder.OpenNamespace(key)
+	t.rec.OpenNamespace(key)
+	if t.namespaces != nil {
+		*t.namespaces = append(*t.namespaces, key)
+	}
+}
+
+// recordArray returns the elements marshaled by marshaler.
+func recordArray(marshaler zapcore.ArrayMarshaler) ([]interface{}, error) {
+	rec := zapcore.NewMapObjectEncoder()
+	err := rec.AddArray("", marshaler)
+	values, _ := rec.Fields[""].([]interface{})
+	return values, err
+}
+
+// fieldsArrayTee forwards the elements to an array encoder and records them.
+type fieldsArrayTee struct {
+	zapcore.ArrayEncoder
+	values []interface{}
+}
+
+func (t *fieldsArrayTee) AppendArray(marshaler zapcore.ArrayMarshaler) error {
+	rec := &fieldsArrayTee{}
+	err := t.ArrayEncoder.AppendArray(zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+		rec.ArrayEncoder = enc
+		return marshaler.MarshalLogArray(rec)
+	}))
+	if rec.ArrayEncoder == nil {
+		rec.values, err = recordArray(marshaler)
+	}
+	t.values = append(t.values, rec.values)
+	return err
+}
+
+func (t *fieldsArrayTee) AppendObject(marshaler zapcore.ObjectMarshaler) error {
+	rec := &fieldsTee{rec: zapcore.NewMapObjectEncoder()}
+	err := t.ArrayEncoder.AppendObject(zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		rec.ObjectEncoder = enc
+		return marshaler.MarshalLogObject(rec)
+	}))
+	if rec.ObjectEncoder == nil {
+		err = marshaler.MarshalLogObject(rec.rec)
+	}
+	t.values = append(t.values, rec.rec.Fields)
+	return err
+}
+
+func (t *fieldsArrayTee) AppendReflected(value interface{}) error {
+	t.values = append(t.values, value)
+	return t.ArrayEncoder.AppendReflected(value)
+}
+
+func (t *fieldsArrayTee) AppendBool(value bool) {
+	t.ArrayEncoder.AppendBool(value)
+	t.values = append(t.values, value)
+}
+
+func (t *fieldsArrayTee) AppendByteString(value []byte) {
+	t.ArrayEncoder.AppendByteString(value)
+	t.values = append(t.values, string(value))
+}
+
+func (t *fieldsArrayTee) AppendComplex128(value complex128) {
+	t.ArrayEncoder.AppendComplex128(value)
+	t.values = append(t.values, value)
+}
+
+func (t *fieldsArrayTee) AppendComplex64(value complex64) {
+	t.ArrayEncoder.AppendComplex64(value)
+	t.values = append(t.values, value)
+}
+
+func (t *fieldsArrayTee) AppendDuration(value time.Duration) {
+	t.ArrayEncoder.AppendDuration(value)
+	t.values = append(t.values, value)
+}
+
+func (t *fieldsArrayTee) AppendFloat64(value float64) {
+	t.ArrayEncoder.AppendFloat64(value)
+	t.values = append(t.values, value)
+}
+
+func (t *fieldsArrayTee) AppendFloat32(value float32) {
+	t.ArrayEncoder.AppendFloat32(value)
+	t.values = append(t.values, value)
+}
+
+func (t *fieldsArrayTee) AppendInt(value int) {
+	t.ArrayEncoder.AppendInt(value)
+	t.values = append(t.values, value)
+}
+
+func (t *fieldsArrayTee) AppendInt64(value int64) {
+	t.ArrayEncoder.AppendInt64(value)
+	t.values = append(t.values, value)
+}
+
+func (t *fieldsArrayTee) AppendInt32(value int32) {
+	t.ArrayEncoder.AppendInt32(value)
+	t.values = append(t.values, value)
+}
+
+func (t *fieldsArrayTee) AppendInt16(value int16) {
+	t.ArrayEncoder.AppendInt16(value)
+	t.values = append(t.values, value)
+}
+
+func (t *fieldsArrayTee) AppendInt8(value int8) {
+	t.ArrayEncoder.AppendInt8(value)
+	t.values = append(t.values, value)
+}
+
+func (t *fieldsArrayTee) AppendString(value string) {
+	t.ArrayEncoder.AppendString(value)
+	t.values = append(t.values, value)
+}
+
+func (t *fieldsArrayTee) AppendTime(value time.Time) {
+	t.ArrayEncoder.AppendTime(value)
+	t.values = append(t.values, value)
+}
+
+func (t *fieldsArrayTee) AppendUint(value uint) {
+	t.ArrayEncoder.AppendUint(value)
+	t.values = append(t.values, value)
+}
+
+func (t *fieldsArrayTee) AppendUint64(value uint64) {
+	t.ArrayEncoder.AppendUint64(value)
+	t.values = append(t.values, value)
+}
+
+func (t *fieldsArrayTee) AppendUint32(value uint32) {
+	t.ArrayEncoder.AppendUint32(value)
+	t.values = append(t.values, value)
+}
+
+func (t *fieldsArrayTee) AppendUint16(value uint16) {
+	t.ArrayEncoder.AppendUint16(value)
+	t.values = append(t.values, value)
+}
+
+func (t *fieldsArrayTee) AppendUint8(value uint8) {
+	t.ArrayEncoder.AppendUint8(value)
+	t.values = append(t.values, value)
+}
+
+func (t *fieldsArrayTee) AppendUintptr(value uintptr) {
+	t.ArrayEncoder.AppendUintptr(value)
+	t.values = append(t.values, value)
+}
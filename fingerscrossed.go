@@ -0,0 +1,211 @@
+package zapappender
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	_ SynchronizationAwareAppender = &FingersCrossed{}
+	_ FieldsAwareAppender          = &FingersCrossed{}
+)
+
+// FingersCrossed buffers messages until a message with a trigger level arrives.
+//
+// The most recent messages are kept in a ring buffer without being forwarded.
+// When a message with a level enabled by the trigger arrives, the buffered messages are forwarded
+// followed by the triggering message. Optionally, all messages are passed through for a duration after
+// a trigger.
+//
+// Messages can be buffered separately per key, e.g. per logger name.
+// To bound the memory usage, the buffers of the least recently used keys are discarded
+// when the number of keys exceeds the limit.
+type FingersCrossed struct {
+	// readonly
+	primary     Appender
+	bufferSize  int
+	trigger     zapcore.LevelEnabler
+	keyFn       func(ent zapcore.Entry) string
+	passThrough time.Duration
+	maxKeys     int
+	now         func() time.Time
+
+	// state
+	mu     sync.Mutex
+	states map[string]*list.Element
+	lru    *list.List
+}
+
+type fingersCrossedState struct {
+	key       string
+	ring      *entryRing
+	passUntil time.Time
+}
+
+type FingersCrossedOption interface {
+	apply(*FingersCrossed) error
+}
+
+type fingersCrossedOptionsFunc func(*FingersCrossed) error
+
+func (f fingersCrossedOptionsFunc) apply(a *FingersCrossed) error {
+	return f(a)
+}
+
+// FingersCrossedBufferSize sets the number of messages buffered per key.
+func FingersCrossedBufferSize(size int) FingersCrossedOption {
+	return fingersCrossedOptionsFunc(func(a *FingersCrossed) error {
+		if size < 1 {
+			return errors.New("size must be at least 1")
+		}
+		a.bufferSize = size
+		return nil
+	})
+}
+
+// FingersCrossedTrigger sets the levels releasing the buffer.
+// Passing a zap.AtomicLevel allows to adjust the trigger at runtime.
+func FingersCrossedTrigger(trigger zapcore.LevelEnabler) FingersCrossedOption {
+	return fingersCrossedOptionsFunc(func(a *FingersCrossed) error {
+		if trigger == nil {
+			return errors.New("trigger must not be nil")
+		}
+		a.trigger = trigger
+		return nil
+	})
+}
+
+// FingersCrossedByLoggerName buffers messages per logger name.
+func FingersCrossedByLoggerName() FingersCrossedOption {
+	return FingersCrossedKeyFn(func(ent zapcore.Entry) string {
+		return ent.LoggerName
+	})
+}
+
+// FingersCrossedKeyFn buffers messages per key returned by keyFn.
+func FingersCrossedKeyFn(keyFn func(ent zapcore.Entry) string) FingersCrossedOption {
+	return fingersCrossedOptionsFunc(func(a *FingersCrossed) error {
+		if keyFn == nil {
+			return errors.New("keyFn must not be nil")
+		}
+		a.keyFn = keyFn
+		return nil
+	})
+}
+
+// FingersCrossedMaxKeys limits the number of keys buffers are kept for.
+func FingersCrossedMaxKeys(maxKeys int) FingersCrossedOption {
+	return fingersCrossedOptionsFunc(func(a *FingersCrossed) error {
+		if maxKeys < 1 {
+			return errors.New("maxKeys must be at least 1")
+		}
+		a.maxKeys = maxKeys
+		return nil
+	})
+}
+
+// FingersCrossedPassThrough forwards all messages of a key for duration after a trigger.
+func FingersCrossedPassThrough(duration time.Duration) FingersCrossedOption {
+	return fingersCrossedOptionsFunc(func(a *FingersCrossed) error {
+		if duration <= time.Duration(0) {
+			return errors.New("duration must be positive")
+		}
+		a.passThrough = duration
+		return nil
+	})
+}
+
+func NewFingersCrossed(primary Appender, options ...FingersCrossedOption) (a *FingersCrossed, err error) {
+	if primary == nil {
+		return nil, errors.New("primary is required")
+	}
+	a = &FingersCrossed{
+		primary:    primary,
+		bufferSize: 100,
+		trigger:    zapcore.ErrorLevel,
+		keyFn:      func(zapcore.Entry) string { return "" },
+		maxKeys:    1000,
+		now:        time.Now,
+		states:     make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+	for _, option := range options {
+		err = option.apply(a)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+func (a *FingersCrossed) Write(p []byte, ent zapcore.Entry) (n int, err error) {
+	return a.WriteFields(p, ent, Fields{})
+}
+
+// WriteFields buffers the fields together with the message.
+func (a *FingersCrossed) WriteFields(p []byte, ent zapcore.Entry, fields Fields) (n int, err error) {
+	key := a.keyFn(ent)
+	now := a.now()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	state := a.state(key)
+	if now.Before(state.passUntil) {
+		return writeWithFields(a.primary, p, ent, fields)
+	}
+	if !a.trigger.Enabled(ent.Level) {
+		state.ring.push(p, ent, fields)
+		return len(p), nil
+	}
+
+	state.ring.each(func(item ringItem) {
+		_, writeErr := writeWithFields(a.primary, item.buf.Bytes(), item.ent, item.fields)
+		err = multierr.Append(err, writeErr)
+	})
+	state.ring.reset()
+	if a.passThrough > 0 {
+		state.passUntil = now.Add(a.passThrough)
+	}
+	n, writeErr := writeWithFields(a.primary, p, ent, fields)
+	return n, multierr.Append(err, writeErr)
+}
+
+func (a *FingersCrossed) WantsFields() bool {
+	return WantsFields(a.primary)
+}
+
+// state returns the state of key and marks it as most recently used.
+// It must be called with the lock held.
+func (a *FingersCrossed) state(key string) *fingersCrossedState {
+	if elem, ok := a.states[key]; ok {
+		a.lru.MoveToFront(elem)
+		return elem.Value.(*fingersCrossedState)
+	}
+	if a.lru.Len() >= a.maxKeys {
+		oldest := a.lru.Back()
+		evicted := a.lru.Remove(oldest).(*fingersCrossedState)
+		evicted.ring.reset()
+		delete(a.states, evicted.key)
+	}
+	state := &fingersCrossedState{
+		key:  key,
+		ring: newEntryRing(a.bufferSize, 0),
+	}
+	a.states[key] = a.lru.PushFront(state)
+	return state
+}
+
+// Sync does not release buffered messages.
+func (a *FingersCrossed) Sync() error {
+	return a.primary.Sync()
+}
+
+func (a *FingersCrossed) Synchronized() bool {
+	return true
+}
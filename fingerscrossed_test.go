@@ -0,0 +1,77 @@
+package zapappender_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/delixfe/zapappender"
+	"github.com/delixfe/zapappender/internal"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestFingersCrossed(t *testing.T) {
+	out := &internal.Buffer{}
+	a, err := zapappender.NewFingersCrossed(zapappender.NewWriter(out),
+		zapappender.FingersCrossedBufferSize(2),
+		zapappender.FingersCrossedByLoggerName(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(logger string, lvl zapcore.Level, msg string) {
+		t.Helper()
+		if _, err := a.Write([]byte(logger+" "+msg+"\n"), zapcore.Entry{LoggerName: logger, Level: lvl}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("a", zapcore.DebugLevel, "1")
+	write("b", zapcore.DebugLevel, "1")
+	write("a", zapcore.DebugLevel, "2")
+	write("a", zapcore.InfoLevel, "3")
+	if out.Len() != 0 {
+		t.Fatalf("expected no output before trigger, got %q", out.String())
+	}
+
+	write("a", zapcore.ErrorLevel, "4")
+	write("a", zapcore.DebugLevel, "5")
+
+	expected := []string{"a 2", "a 3", "a 4"}
+	if got := out.Lines(); len(got) != len(expected) || got[0] != expected[0] || got[1] != expected[1] || got[2] != expected[2] {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestFingersCrossed_passThrough(t *testing.T) {
+	primary, written := NewWriteCountingAppender()
+	a, err := zapappender.NewFingersCrossed(primary,
+		zapappender.FingersCrossedTrigger(zapcore.WarnLevel),
+		zapappender.FingersCrossedPassThrough(time.Hour),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = a.Write([]byte{}, zapcore.Entry{Level: zapcore.DebugLevel})
+	AssertWrittenEquals(t, 0, written, "before trigger")
+	_, _ = a.Write([]byte{}, zapcore.Entry{Level: zapcore.WarnLevel})
+	AssertWrittenEquals(t, 2, written, "after trigger")
+	_, _ = a.Write([]byte{}, zapcore.Entry{Level: zapcore.DebugLevel})
+	AssertWrittenEquals(t, 3, written, "passing through")
+}
+
+func TestFingersCrossed_maxKeys_evictsLeastRecentlyUsed(t *testing.T) {
+	primary, written := NewWriteCountingAppender()
+	a, err := zapappender.NewFingersCrossed(primary,
+		zapappender.FingersCrossedByLoggerName(),
+		zapappender.FingersCrossedMaxKeys(1),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = a.Write([]byte{}, zapcore.Entry{LoggerName: "a"})
+	_, _ = a.Write([]byte{}, zapcore.Entry{LoggerName: "b"})
+	_, _ = a.Write([]byte{}, zapcore.Entry{LoggerName: "a", Level: zapcore.ErrorLevel})
+	AssertWrittenEquals(t, 1, written, "buffer of a was evicted")
+}
@@ -0,0 +1,151 @@
+package zapappender
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	_ SynchronizationAwareAppender = &FlightRecorder{}
+	_ FieldsAwareAppender          = &FlightRecorder{}
+)
+
+// FlightRecorder retains the most recent messages in memory and forwards all messages to the primary appender.
+//
+// The recorded history can be written out on demand with Dump, e.g. from a debug handler or on panic.
+// Place it in front of any LevelFilter to record levels that are not shipped.
+// Note that the LevelEnabler of the AppenderCore must enable all levels that should be recorded.
+// The structured fields are only recorded if the primary appender wants them, see FieldsAware.
+type FlightRecorder struct {
+	// only during construction
+	maxEntries int
+	maxBytes   int
+
+	// readonly
+	primary Appender
+
+	// state
+	mu   sync.Mutex
+	ring *entryRing
+}
+
+// FlightRecord is a recorded message.
+type FlightRecord struct {
+	Entry   zapcore.Entry
+	Payload []byte
+	Fields  Fields
+}
+
+type FlightRecorderOption interface {
+	apply(*FlightRecorder) error
+}
+
+type flightRecorderOptionsFunc func(*FlightRecorder) error
+
+func (f flightRecorderOptionsFunc) apply(a *FlightRecorder) error {
+	return f(a)
+}
+
+// FlightRecorderMaxEntries limits the number of retained messages. The default is 1000.
+func FlightRecorderMaxEntries(maxEntries int) FlightRecorderOption {
+	return flightRecorderOptionsFunc(func(a *FlightRecorder) error {
+		if maxEntries < 1 {
+			return errors.New("maxEntries must be at least 1")
+		}
+		a.maxEntries = maxEntries
+		return nil
+	})
+}
+
+// FlightRecorderMaxBytes limits the total size of the retained messages.
+// The most recent message is always retained.
+func FlightRecorderMaxBytes(maxBytes int) FlightRecorderOption {
+	return flightRecorderOptionsFunc(func(a *FlightRecorder) error {
+		if maxBytes < 1 {
+			return errors.New("maxBytes must be at least 1")
+		}
+		a.maxBytes = maxBytes
+		return nil
+	})
+}
+
+// NewFlightRecorder creates a FlightRecorder.
+// primary might be nil to only record the messages.
+func NewFlightRecorder(primary Appender, options ...FlightRecorderOption) (a *FlightRecorder, err error) {
+	if primary == nil {
+		primary = NewDiscard()
+	}
+	a = &FlightRecorder{
+		maxEntries: 1000,
+		primary:    primary,
+	}
+	for _, option := range options {
+		err = option.apply(a)
+		if err != nil {
+			return nil, err
+		}
+	}
+	a.ring = newEntryRing(a.maxEntries, a.maxBytes)
+	return a, nil
+}
+
+func (a *FlightRecorder) Write(p []byte, ent zapcore.Entry) (n int, err error) {
+	return a.WriteFields(p, ent, Fields{})
+}
+
+func (a *FlightRecorder) WriteFields(p []byte, ent zapcore.Entry, fields Fields) (n int, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.ring.push(p, ent, fields)
+	return writeWithFields(a.primary, p, ent, fields)
+}
+
+func (a *FlightRecorder) WantsFields() bool {
+	return WantsFields(a.primary)
+}
+
+func (a *FlightRecorder) Sync() error {
+	return a.primary.Sync()
+}
+
+func (a *FlightRecorder) Synchronized() bool {
+	return true
+}
+
+// Dump writes the recorded messages from the oldest to the newest to w.
+// Recording continues while dumping, w does not block the recording.
+func (a *FlightRecorder) Dump(w io.Writer) (n int64, err error) {
+	for _, record := range a.Snapshot() {
+		written, err := w.Write(record.Payload)
+		n += int64(written)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Snapshot returns copies of the recorded messages from the oldest to the newest.
+func (a *FlightRecorder) Snapshot() []FlightRecord {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	records := make([]FlightRecord, 0, a.ring.len())
+	a.ring.each(func(item ringItem) {
+		records = append(records, FlightRecord{
+			Entry:   item.ent,
+			Payload: append([]byte(nil), item.buf.Bytes()...),
+			Fields:  item.fields,
+		})
+	})
+	return records
+}
+
+// Reset discards the recorded messages.
+func (a *FlightRecorder) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.ring.reset()
+}
@@ -0,0 +1,62 @@
+package zapappender_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/delixfe/zapappender"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestFlightRecorder(t *testing.T) {
+	shipped, shippedWritten := NewWriteCountingAppender()
+	recorder, err := zapappender.NewFlightRecorder(
+		zapappender.NewLevelFilter(shipped, zapcore.WarnLevel),
+		zapappender.FlightRecorderMaxEntries(3),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := zap.New(zapappender.NewAppenderCore(zapcore.NewConsoleEncoder(encoderConfig), recorder, zapcore.DebugLevel))
+
+	logger.Debug("one")
+	logger.Debug("two")
+	logger.Info("three")
+	logger.Warn("four")
+
+	AssertWrittenEquals(t, 1, shippedWritten, "shipped")
+
+	var dump bytes.Buffer
+	if _, err := recorder.Dump(&dump); err != nil {
+		t.Fatal(err)
+	}
+	expected := "debug ** two\ninfo ** three\nwarn ** four\n"
+	if dump.String() != expected {
+		t.Errorf("expected dump %q, got %q", expected, dump.String())
+	}
+
+	snapshot := recorder.Snapshot()
+	if len(snapshot) != 3 || snapshot[0].Entry.Message != "two" {
+		t.Errorf("unexpected snapshot %v", snapshot)
+	}
+}
+
+func TestFlightRecorder_maxBytes(t *testing.T) {
+	recorder, err := zapappender.NewFlightRecorder(nil, zapappender.FlightRecorderMaxBytes(8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range []string{"aaaa", "bbbb", "cccc", "dddddddddd"} {
+		_, _ = recorder.Write([]byte(msg), zapcore.Entry{})
+	}
+	snapshot := recorder.Snapshot()
+	if len(snapshot) != 1 || string(snapshot[0].Payload) != "dddddddddd" {
+		t.Errorf("expected only the newest message, got %v", snapshot)
+	}
+
+	recorder.Reset()
+	if len(recorder.Snapshot()) != 0 {
+		t.Error("expected an empty recorder after reset")
+	}
+}
@@ -0,0 +1,274 @@
+package zapappender
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/delixfe/zapappender/internal/bufferpool"
+	"github.com/delixfe/zapappender/internal/msgpack"
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+var _ SynchronizationAwareAppender = &Fluentd{}
+
+// Fluentd sends messages using the Fluentd Forward protocol, e.g. to fluent-bit or fluentd.
+//
+// Messages are batched per tag and sent in PackedForward mode. Each event consists of the entry time
+// as EventTime and a record containing the encoded message under the record key.
+// Optionally, the server must acknowledge each batch.
+type Fluentd struct {
+	// only during construction
+	batchSize     int
+	batchBytes    int
+	flushInterval time.Duration
+
+	// readonly
+	network    string
+	address    string
+	tagFn      func(ent zapcore.Entry) string
+	recordKey  string
+	requireAck bool
+	timeout    time.Duration
+	batch      *batcher
+
+	// state
+	mu      sync.Mutex
+	conn    net.Conn
+	decoder *msgpack.Decoder
+}
+
+type FluentdOption interface {
+	apply(*Fluentd) error
+}
+
+type fluentdOptionsFunc func(*Fluentd) error
+
+func (f fluentdOptionsFunc) apply(a *Fluentd) error {
+	return f(a)
+}
+
+// FluentdTag sets a static tag. The default tag is "zap".
+func FluentdTag(tag string) FluentdOption {
+	return FluentdTagFn(func(zapcore.Entry) string { return tag })
+}
+
+// FluentdTagFn derives the tag from the entry.
+func FluentdTagFn(tagFn func(ent zapcore.Entry) string) FluentdOption {
+	return fluentdOptionsFunc(func(a *Fluentd) error {
+		if tagFn == nil {
+			return errors.New("tagFn must not be nil")
+		}
+		a.tagFn = tagFn
+		return nil
+	})
+}
+
+// FluentdRecordKey sets the record key of the encoded message. The default is "log".
+func FluentdRecordKey(key string) FluentdOption {
+	return fluentdOptionsFunc(func(a *Fluentd) error {
+		if key == "" {
+			return errors.New("key must not be empty")
+		}
+		a.recordKey = key
+		return nil
+	})
+}
+
+// FluentdRequireAck requires the server to acknowledge each batch.
+func FluentdRequireAck() FluentdOption {
+	return fluentdOptionsFunc(func(a *Fluentd) error {
+		a.requireAck = true
+		return nil
+	})
+}
+
+// FluentdTimeout limits connecting, sending and waiting for the acknowledgement. The default is 5 seconds.
+func FluentdTimeout(timeout time.Duration) FluentdOption {
+	return fluentdOptionsFunc(func(a *Fluentd) error {
+		if timeout <= time.Duration(0) {
+			return errors.New("timeout must be positive")
+		}
+		a.timeout = timeout
+		return nil
+	})
+}
+
+// FluentdBatchSize sets the maximum number of events per batch. The default is 100.
+func FluentdBatchSize(size int) FluentdOption {
+	return fluentdOptionsFunc(func(a *Fluentd) error {
+		if size < 1 {
+			return errors.New("size must be at least 1")
+		}
+		a.batchSize = size
+		return nil
+	})
+}
+
+// FluentdBatchBytes sets the maximum size of the events per batch. The default is 1 MiB.
+func FluentdBatchBytes(size int) FluentdOption {
+	return fluentdOptionsFunc(func(a *Fluentd) error {
+		if size < 1 {
+			return errors.New("size must be at least 1")
+		}
+		a.batchBytes = size
+		return nil
+	})
+}
+
+// FluentdFlushInterval sets the interval in which incomplete batches are sent. The default is 1 second.
+func FluentdFlushInterval(interval time.Duration) FluentdOption {
+	return fluentdOptionsFunc(func(a *Fluentd) error {
+		if interval <= time.Duration(0) {
+			return errors.New("interval must be positive")
+		}
+		a.flushInterval = interval
+		return nil
+	})
+}
+
+// NewFluentd creates a Fluentd appender connecting to address on network, which is either tcp or unix.
+// The connection is established lazily and reestablished after errors.
+func NewFluentd(network, address string, options ...FluentdOption) (a *Fluentd, err error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "unix":
+	default:
+		return nil, fmt.Errorf("unsupported network %q", network)
+	}
+	a = &Fluentd{
+		batchSize:     100,
+		batchBytes:    1024 * 1024,
+		flushInterval: time.Second,
+		network:       network,
+		address:       address,
+		recordKey:     "log",
+		timeout:       5 * time.Second,
+	}
+	FluentdTag("zap").apply(a)
+
+	for _, option := range options {
+		err = option.apply(a)
+		if err != nil {
+			return nil, err
+		}
+	}
+	a.batch = newBatcher(a.batchSize, a.batchBytes, a.flushInterval, a.send)
+	return a, nil
+}
+
+func (a *Fluentd) Write(p []byte, ent zapcore.Entry) (n int, err error) {
+	buf := bufferpool.Get()
+	msgpack.AppendArrayHeader(buf, 2)
+	msgpack.AppendEventTime(buf, ent.Time)
+	msgpack.AppendMapHeader(buf, 1)
+	msgpack.AppendString(buf, a.recordKey)
+	msgpack.AppendStringBytes(buf, trimNewline(p))
+
+	if err = a.batch.add(a.tagFn(ent), buf, ent); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// send is called by the batcher, the calls are serialized.
+func (a *Fluentd) send(tag string, items []batchItem) error {
+	size := 0
+	for _, item := range items {
+		size += item.buf.Len()
+	}
+
+	msg := bufferpool.Get()
+	defer msg.Free()
+	msgpack.AppendArrayHeader(msg, 3)
+	msgpack.AppendString(msg, tag)
+	msgpack.AppendBinaryHeader(msg, size)
+	for _, item := range items {
+		_, _ = msg.Write(item.buf.Bytes())
+	}
+	var chunk string
+	if a.requireAck {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return err
+		}
+		chunk = base64.StdEncoding.EncodeToString(id)
+		msgpack.AppendMapHeader(msg, 2)
+		msgpack.AppendString(msg, "chunk")
+		msgpack.AppendString(msg, chunk)
+	} else {
+		msgpack.AppendMapHeader(msg, 1)
+	}
+	msgpack.AppendString(msg, "size")
+	msgpack.AppendUint(msg, uint64(len(items)))
+
+	err := a.sendMessage(msg.Bytes(), chunk)
+	if err != nil && !a.requireAck {
+		// the server might have closed an idle connection, retry once on a new one
+		err = a.sendMessage(msg.Bytes(), chunk)
+	}
+	return err
+}
+
+func (a *Fluentd) sendMessage(msg []byte, chunk string) (err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	defer func() {
+		if err != nil && a.conn != nil {
+			err = multierr.Append(err, a.conn.Close())
+			a.conn = nil
+		}
+	}()
+
+	if a.conn == nil {
+		conn, err := net.DialTimeout(a.network, a.address, a.timeout)
+		if err != nil {
+			return err
+		}
+		a.conn = conn
+		a.decoder = msgpack.NewDecoder(conn)
+	}
+	if err = a.conn.SetDeadline(time.Now().Add(a.timeout)); err != nil {
+		return err
+	}
+	if _, err = a.conn.Write(msg); err != nil {
+		return err
+	}
+	if chunk == "" {
+		return nil
+	}
+
+	resp, err := a.decoder.Decode()
+	if err != nil {
+		return err
+	}
+	if m, ok := resp.(map[interface{}]interface{}); ok && m["ack"] == chunk {
+		return nil
+	}
+	return errors.New("fluentd: invalid acknowledgement")
+}
+
+// Sync sends the pending batches.
+func (a *Fluentd) Sync() error {
+	return a.batch.flush()
+}
+
+func (a *Fluentd) Synchronized() bool {
+	return true
+}
+
+// Shutdown sends the pending batches and closes the connection.
+func (a *Fluentd) Shutdown() error {
+	err := a.batch.stop()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.conn != nil {
+		err = multierr.Append(err, a.conn.Close())
+		a.conn = nil
+	}
+	return err
+}
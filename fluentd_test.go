@@ -0,0 +1,147 @@
+package zapappender_test
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/delixfe/zapappender"
+	"github.com/delixfe/zapappender/internal/msgpack"
+	"go.uber.org/zap/zapcore"
+)
+
+type forwardMessage struct {
+	tag     string
+	events  [][]interface{}
+	options map[interface{}]interface{}
+}
+
+// startFluentdServer accepts one connection and decodes PackedForward messages.
+func startFluentdServer(t *testing.T) (addr string, messages chan forwardMessage) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	messages = make(chan forwardMessage, 10)
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		decoder := msgpack.NewDecoder(conn)
+		for {
+			v, err := decoder.Decode()
+			if err != nil {
+				return
+			}
+			arr := v.([]interface{})
+			msg := forwardMessage{
+				tag:     arr[0].(string),
+				options: arr[2].(map[interface{}]interface{}),
+			}
+			entries := msgpack.NewDecoder(bytes.NewReader(arr[1].([]byte)))
+			for {
+				event, err := entries.Decode()
+				if err != nil {
+					break
+				}
+				msg.events = append(msg.events, event.([]interface{}))
+			}
+			if chunk, ok := msg.options["chunk"]; ok {
+				ack := map[string]string{"ack": chunk.(string)}
+				if _, err := conn.Write(encodeAck(ack)); err != nil {
+					return
+				}
+			}
+			messages <- msg
+		}
+	}()
+	return listener.Addr().String(), messages
+}
+
+func encodeAck(ack map[string]string) []byte {
+	// fixmap with one fixstr key and a str8 value
+	b := []byte{0x81, 0xa3, 'a', 'c', 'k', 0xd9, byte(len(ack["ack"]))}
+	return append(b, ack["ack"]...)
+}
+
+func TestFluentd(t *testing.T) {
+	addr, messages := startFluentdServer(t)
+
+	a, err := zapappender.NewFluentd("tcp", addr,
+		zapappender.FluentdTagFn(func(ent zapcore.Entry) string { return "app." + ent.LoggerName }),
+		zapappender.FluentdRecordKey("message"),
+		zapappender.FluentdRequireAck(),
+		zapappender.FluentdBatchSize(2),
+		zapappender.FluentdFlushInterval(time.Hour),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Shutdown()
+
+	ts := time.Unix(1600000000, 42)
+	for _, msg := range []string{"one\n", "two\n"} {
+		if _, err := a.Write([]byte(msg), zapcore.Entry{LoggerName: "http", Time: ts}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case msg := <-messages:
+		if msg.tag != "app.http" {
+			t.Errorf("unexpected tag %q", msg.tag)
+		}
+		if msg.options["size"] != int64(2) {
+			t.Errorf("unexpected size option %v", msg.options["size"])
+		}
+		if len(msg.events) != 2 {
+			t.Fatalf("expected 2 events, got %d", len(msg.events))
+		}
+		eventTime := msg.events[0][0].(msgpack.Ext)
+		if eventTime.Type != 0 || !bytes.Equal(eventTime.Data, []byte{0x5f, 0x5e, 0x10, 0x00, 0, 0, 0, 42}) {
+			t.Errorf("unexpected event time %v", eventTime)
+		}
+		record := msg.events[1][1].(map[interface{}]interface{})
+		if record["message"] != "two" {
+			t.Errorf("unexpected record %v", record)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+
+	// incomplete batch is sent on Sync
+	if _, err := a.Write([]byte("three\n"), zapcore.Entry{LoggerName: "db", Time: ts}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	msg := <-messages
+	if msg.tag != "app.db" || len(msg.events) != 1 {
+		t.Errorf("unexpected message %v", msg)
+	}
+}
+
+func TestFluentd_unreachable_returnsErr(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	_ = listener.Close()
+
+	a, err := zapappender.NewFluentd("tcp", addr, zapappender.FluentdBatchSize(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Shutdown()
+	if _, err := a.Write([]byte("lost\n"), zapcore.Entry{}); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,100 @@
+package zapappender
+
+import (
+	"strings"
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// trimNewline removes a trailing line ending from p.
+func trimNewline(p []byte) []byte {
+	if n := len(p); n > 0 && p[n-1] == '\n' {
+		p = p[:n-1]
+		if n := len(p); n > 0 && p[n-1] == '\r' {
+			p = p[:n-1]
+		}
+	}
+	return p
+}
+
+// appendTrimmedCaller is the no-alloc equivalent of buf.AppendString(caller.TrimmedPath()).
+func appendTrimmedCaller(buf *buffer.Buffer, caller zapcore.EntryCaller) {
+	if !caller.Defined {
+		buf.AppendString("undefined")
+		return
+	}
+	file := caller.File
+	if idx := strings.LastIndexByte(file, '/'); idx != -1 {
+		if idx = strings.LastIndexByte(file[:idx], '/'); idx != -1 {
+			file = file[idx+1:]
+		}
+	}
+	buf.AppendString(file)
+	buf.AppendByte(':')
+	buf.AppendInt(int64(caller.Line))
+}
+
+// appendFullCaller is the no-alloc equivalent of buf.AppendString(caller.FullPath()).
+func appendFullCaller(buf *buffer.Buffer, caller zapcore.EntryCaller) {
+	if !caller.Defined {
+		buf.AppendString("undefined")
+		return
+	}
+	buf.AppendString(caller.File)
+	buf.AppendByte(':')
+	buf.AppendInt(int64(caller.Line))
+}
+
+// appendUnixFraction appends the seconds since the epoch with digits fractional digits.
+func appendUnixFraction(buf *buffer.Buffer, t time.Time, digits int) {
+	nanos := t.UnixNano()
+	seconds := nanos / int64(time.Second)
+	fraction := nanos % int64(time.Second)
+	if fraction < 0 {
+		seconds--
+		fraction += int64(time.Second)
+	}
+	buf.AppendInt(seconds)
+	if digits <= 0 {
+		return
+	}
+	buf.AppendByte('.')
+	divisor := int64(time.Second)
+	for i := 0; i < digits && i < 9; i++ {
+		divisor /= 10
+		buf.AppendByte(byte('0' + fraction/divisor%10))
+	}
+}
+
+// byteEscaper maps each byte to its replacement; an empty replacement copies the byte.
+type byteEscaper [256]string
+
+func newByteEscaper(replacements map[byte]string) *byteEscaper {
+	e := &byteEscaper{}
+	for c, r := range replacements {
+		e[c] = r
+	}
+	return e
+}
+
+func (e *byteEscaper) appendBytes(buf *buffer.Buffer, b []byte) {
+	for _, c := range b {
+		if r := e[c]; r != "" {
+			buf.AppendString(r)
+		} else {
+			buf.AppendByte(c)
+		}
+	}
+}
+
+func (e *byteEscaper) appendString(buf *buffer.Buffer, s string) {
+	for i := 0; i < len(s); i++ {
+		if r := e[s[i]]; r != "" {
+			buf.AppendString(r)
+		} else {
+			buf.AppendByte(s[i])
+		}
+	}
+}
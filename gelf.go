@@ -0,0 +1,256 @@
+package zapappender
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/delixfe/zapappender/internal/bufferpool"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// GELFConfig configures the GELF envelope.
+type GELFConfig struct {
+	// Host is the name of the host, source or application that sent the message.
+	Host string
+	// AdditionalFields are added to every message. The keys must not contain the leading underscore.
+	AdditionalFields map[string]string
+}
+
+// NewGELFEnvelopingFn creates an EnvelopingFn wrapping the encoded message into a GELF 1.1 document.
+//
+// The encoded message without its line ending becomes the short_message. If the entry has a stack,
+// the full_message contains the encoded message followed by the stack.
+// The level is mapped to the syslog severity, logger name and caller are added as _logger and _caller.
+// The document is not terminated; use NewGELFUDP or NewGELFTCPFraming as transport.
+func NewGELFEnvelopingFn(config GELFConfig) EnvelopingFn {
+	static := bufferpool.Get()
+	static.AppendString(`{"version":"1.1","host":`)
+	appendJSONString(static, config.Host)
+	static.AppendString(`,"short_message":`)
+	prefix := static.String()
+	static.Reset()
+
+	keys := make([]string, 0, len(config.AdditionalFields))
+	for key := range config.AdditionalFields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		static.AppendByte(',')
+		appendJSONString(static, "_"+key)
+		static.AppendByte(':')
+		appendJSONString(static, config.AdditionalFields[key])
+	}
+	additional := static.String()
+	static.Free()
+
+	return func(p []byte, ent zapcore.Entry, output *buffer.Buffer) error {
+		msg := trimNewline(p)
+		output.AppendString(prefix)
+		appendJSONBytes(output, msg)
+		if ent.Stack != "" {
+			output.AppendString(`,"full_message":"`)
+			appendJSONEscapedBytes(output, msg)
+			output.AppendString(`\n`)
+			appendJSONEscapedString(output, ent.Stack)
+			output.AppendByte('"')
+		}
+		output.AppendString(`,"timestamp":`)
+		appendUnixFraction(output, ent.Time, 3)
+		output.AppendString(`,"level":`)
+		output.AppendInt(int64(syslogSeverity(ent.Level)))
+		if ent.LoggerName != "" {
+			output.AppendString(`,"_logger":`)
+			appendJSONString(output, ent.LoggerName)
+		}
+		if ent.Caller.Defined {
+			output.AppendString(`,"_caller":`)
+			caller := bufferpool.Get()
+			appendTrimmedCaller(caller, ent.Caller)
+			appendJSONBytes(output, caller.Bytes())
+			caller.Free()
+		}
+		output.AppendString(additional)
+		output.AppendByte('}')
+		return nil
+	}
+}
+
+// NewGELFTCPFraming terminates each GELF document with a null byte as required by the GELF TCP input.
+func NewGELFTCPFraming(inner Appender) *Enveloping {
+	return NewEnvelopingPreSuffix(inner, "", "\x00")
+}
+
+// GELFCompression is the compression used by GELFUDP.
+type GELFCompression int
+
+const (
+	GELFCompressionNone GELFCompression = iota
+	GELFCompressionGzip
+	GELFCompressionZlib
+)
+
+const (
+	gelfChunkHeaderSize = 12
+	gelfMaxChunks       = 128
+)
+
+var ErrGELFMessageTooLarge = errors.New("gelf message exceeds the maximum number of chunks")
+
+var _ SynchronizationAwareAppender = &GELFUDP{}
+
+// GELFUDP sends each message as GELF UDP datagram.
+//
+// Messages exceeding the chunk size are split into GELF chunks.
+// Optionally, the messages are compressed with gzip or zlib before chunking.
+type GELFUDP struct {
+	// readonly
+	conn        net.Conn
+	compression GELFCompression
+	chunkSize   int
+
+	// state
+	mu         sync.Mutex
+	compressed bytes.Buffer
+	gzip       *gzip.Writer
+	zlib       *zlib.Writer
+	chunk      []byte
+}
+
+type GELFUDPOption interface {
+	apply(*GELFUDP) error
+}
+
+type gelfUDPOptionsFunc func(*GELFUDP) error
+
+func (f gelfUDPOptionsFunc) apply(a *GELFUDP) error {
+	return f(a)
+}
+
+// GELFUDPCompression sets the compression. The default is GELFCompressionNone.
+func GELFUDPCompression(compression GELFCompression) GELFUDPOption {
+	return gelfUDPOptionsFunc(func(a *GELFUDP) error {
+		switch compression {
+		case GELFCompressionNone, GELFCompressionGzip, GELFCompressionZlib:
+		default:
+			return errors.New("unknown compression")
+		}
+		a.compression = compression
+		return nil
+	})
+}
+
+// GELFUDPChunkSize sets the maximum size of a datagram. The default of 1420 bytes is suitable for WAN links.
+func GELFUDPChunkSize(size int) GELFUDPOption {
+	return gelfUDPOptionsFunc(func(a *GELFUDP) error {
+		if size <= gelfChunkHeaderSize {
+			return errors.New("size must be greater than the chunk header")
+		}
+		a.chunkSize = size
+		return nil
+	})
+}
+
+// NewGELFUDP creates a GELFUDP sending to conn, which is usually created with net.Dial("udp", addr).
+func NewGELFUDP(conn net.Conn, options ...GELFUDPOption) (a *GELFUDP, err error) {
+	if conn == nil {
+		return nil, errors.New("conn is required")
+	}
+	a = &GELFUDP{
+		conn:      conn,
+		chunkSize: 1420,
+	}
+	for _, option := range options {
+		err = option.apply(a)
+		if err != nil {
+			return nil, err
+		}
+	}
+	a.chunk = make([]byte, a.chunkSize)
+	return a, nil
+}
+
+func (a *GELFUDP) Write(p []byte, _ zapcore.Entry) (n int, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	data, err := a.compress(p)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) <= a.chunkSize {
+		if _, err = a.conn.Write(data); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	payloadSize := a.chunkSize - gelfChunkHeaderSize
+	count := (len(data) + payloadSize - 1) / payloadSize
+	if count > gelfMaxChunks {
+		return 0, ErrGELFMessageTooLarge
+	}
+	a.chunk[0], a.chunk[1] = 0x1e, 0x0f
+	if _, err = rand.Read(a.chunk[2:10]); err != nil {
+		return 0, err
+	}
+	a.chunk[11] = byte(count)
+	for seq := 0; seq < count; seq++ {
+		a.chunk[10] = byte(seq)
+		end := (seq + 1) * payloadSize
+		if end > len(data) {
+			end = len(data)
+		}
+		size := copy(a.chunk[gelfChunkHeaderSize:], data[seq*payloadSize:end])
+		if _, err = a.conn.Write(a.chunk[:gelfChunkHeaderSize+size]); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// compress must be called with the lock held
+func (a *GELFUDP) compress(p []byte) ([]byte, error) {
+	var w io.WriteCloser
+	switch a.compression {
+	case GELFCompressionGzip:
+		if a.gzip == nil {
+			a.gzip = gzip.NewWriter(&a.compressed)
+		}
+		w = a.gzip
+	case GELFCompressionZlib:
+		if a.zlib == nil {
+			a.zlib = zlib.NewWriter(&a.compressed)
+		}
+		w = a.zlib
+	default:
+		return p, nil
+	}
+	a.compressed.Reset()
+	if r, ok := w.(interface{ Reset(io.Writer) }); ok {
+		r.Reset(&a.compressed)
+	}
+	if _, err := w.Write(p); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return a.compressed.Bytes(), nil
+}
+
+func (a *GELFUDP) Sync() error {
+	return nil
+}
+
+func (a *GELFUDP) Synchronized() bool {
+	return true
+}
@@ -0,0 +1,151 @@
+package zapappender_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/delixfe/zapappender"
+	"github.com/delixfe/zapappender/internal"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestGELFEnvelopingFn(t *testing.T) {
+	out := &internal.Buffer{}
+	a := zapappender.NewEnveloping(zapappender.NewWriter(out), zapappender.NewGELFEnvelopingFn(zapappender.GELFConfig{
+		Host:             "web-1",
+		AdditionalFields: map[string]string{"env": "prod", "app": "shop"},
+	}))
+	ent := zapcore.Entry{
+		Level:      zapcore.ErrorLevel,
+		Time:       time.Unix(1600000000, 123456789),
+		LoggerName: "http",
+		Caller:     zapcore.NewEntryCaller(0, "/src/app/http/handler.go", 42, true),
+		Stack:      "goroutine 1\n\tmain.go:1",
+	}
+	if _, err := a.Write([]byte("failed \"quoted\"\n"), ent); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out.String(), err)
+	}
+	expected := map[string]interface{}{
+		"version":       "1.1",
+		"host":          "web-1",
+		"short_message": `failed "quoted"`,
+		"full_message":  "failed \"quoted\"\ngoroutine 1\n\tmain.go:1",
+		"timestamp":     1600000000.123,
+		"level":         float64(3),
+		"_logger":       "http",
+		"_caller":       "http/handler.go:42",
+		"_env":          "prod",
+		"_app":          "shop",
+	}
+	if len(doc) != len(expected) {
+		t.Errorf("expected %d fields, got %v", len(expected), doc)
+	}
+	for key, value := range expected {
+		if doc[key] != value {
+			t.Errorf("%s: expected %v, got %v", key, value, doc[key])
+		}
+	}
+}
+
+func TestGELFUDP_chunked(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	conn, err := net.Dial("udp", listener.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	a, err := zapappender.NewGELFUDP(conn,
+		zapappender.GELFUDPChunkSize(100),
+		zapappender.GELFUDPCompression(zapappender.GELFCompressionGzip),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// random-ish content does not compress into a single chunk
+	var sb strings.Builder
+	for i := 0; sb.Len() < 2000; i++ {
+		sb.WriteString(time.Duration(i * 7919).String())
+	}
+	msg := `{"short_message":"` + sb.String() + `"}`
+	if _, err := a.Write([]byte(msg), zapcore.Entry{}); err != nil {
+		t.Fatal(err)
+	}
+
+	chunks := map[byte][]byte{}
+	var id []byte
+	count := -1
+	datagram := make([]byte, 200)
+	_ = listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for count == -1 || len(chunks) < count {
+		n, _, err := listener.ReadFrom(datagram)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n > 100 {
+			t.Fatalf("datagram of %d bytes exceeds chunk size", n)
+		}
+		if datagram[0] != 0x1e || datagram[1] != 0x0f {
+			t.Fatalf("missing chunk magic bytes")
+		}
+		if id == nil {
+			id = append([]byte(nil), datagram[2:10]...)
+		} else if !bytes.Equal(id, datagram[2:10]) {
+			t.Fatalf("chunks with different message ids")
+		}
+		count = int(datagram[11])
+		chunks[datagram[10]] = append([]byte(nil), datagram[12:n]...)
+	}
+	if count < 2 {
+		t.Fatalf("expected multiple chunks, got %d", count)
+	}
+
+	var compressed []byte
+	for seq := 0; seq < count; seq++ {
+		compressed = append(compressed, chunks[byte(seq)]...)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != msg {
+		t.Errorf("reassembled message differs")
+	}
+}
+
+func TestGELFUDP_tooLarge_returnsErr(t *testing.T) {
+	conn, err := net.Dial("udp", "127.0.0.1:9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	a, err := zapappender.NewGELFUDP(conn, zapappender.GELFUDPChunkSize(13))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = a.Write(make([]byte, 129), zapcore.Entry{})
+	if !errors.Is(err, zapappender.ErrGELFMessageTooLarge) {
+		t.Errorf("expected ErrGELFMessageTooLarge, got %v", err)
+	}
+}
@@ -5,6 +5,10 @@ go 1.17
 require (
 	go.uber.org/multierr v1.7.0
 	go.uber.org/zap v1.20.0
+	golang.org/x/term v0.0.0-20210927222741-03fcf44c2211
 )
 
-require go.uber.org/atomic v1.9.0 // indirect
+require (
+	go.uber.org/atomic v1.9.0 // indirect
+	golang.org/x/sys v0.0.0-20210615035016-665e8c7367d1 // indirect
+)
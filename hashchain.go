@@ -0,0 +1,375 @@
+package zapappender
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/delixfe/zapappender/internal/bufferpool"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	hashChainSeparator           = "\tchain="
+	hashChainCheckpointSeparator = "\tcheckpoint="
+	hashChainCheckpoint          = "#checkpoint "
+)
+
+var (
+	_ SynchronizationAwareAppender = &HashChain{}
+	_ FieldsAwareAppender          = &HashChain{}
+)
+
+// HashChain makes a log tamper-evident by appending a chain value to every line.
+//
+// The chain value of a record is the SHA-256, or with HashChainHMAC the HMAC-SHA256, over the chain value
+// of the previous record and the encoded message without its line ending:
+//
+//	<message>\tchain=<hex>
+//
+// Before the first record and then every HashChainCheckpointEvery records, a checkpoint record is written.
+// It contains the number of records written so far and the previous chain value and is chained itself.
+// Its chain value is marked as checkpoint, which records never are, even if the message looks like a checkpoint:
+//
+//	#checkpoint seq=<n> time=<RFC3339Nano> prev=<hex>\tcheckpoint=<hex>
+//
+// A log starting with a checkpoint can be verified on its own, which allows to rotate files.
+// The chain of a new process starts with a checkpoint with seq=0 and an all zero prev.
+// Such a restart is not linked to the previous chain: whoever can write the log can remove its tail and
+// start a new chain. VerifyHashChain therefore rejects restarts unless HashChainAllowRestarts is given.
+//
+// Without a key, anybody can recompute the chain after modifying the log; use HashChainHMAC and
+// keep the key away from the log storage. Removing records at the end of the log cannot be detected from the
+// log alone; store the latest checkpoints elsewhere if that matters.
+// Use VerifyHashChain or cmd/verifyhashchain to verify a log.
+//
+// Messages containing line breaks are chained as a whole, only their last line gets the chain value.
+// VerifyHashChain cannot tell an inner line ending in a tab followed by chain= or checkpoint= and 64 hex
+// digits from the end of a record and reports a valid log containing such a message as broken.
+type HashChain struct {
+	// readonly
+	primary         Appender
+	newHash         func() hash.Hash
+	checkpointEvery uint64
+	now             func() time.Time
+	allowRestarts   bool // only used by VerifyHashChain
+
+	// state
+	mu         sync.Mutex
+	hash       hash.Hash
+	chain      [sha256.Size]byte
+	seq        uint64
+	checkpoint bool
+}
+
+type HashChainOption interface {
+	apply(*HashChain) error
+}
+
+type hashChainOptionsFunc func(*HashChain) error
+
+func (f hashChainOptionsFunc) apply(a *HashChain) error {
+	return f(a)
+}
+
+// HashChainHMAC keys the chain with HMAC-SHA256 instead of plain SHA-256.
+func HashChainHMAC(key []byte) HashChainOption {
+	return hashChainOptionsFunc(func(a *HashChain) error {
+		if len(key) == 0 {
+			return errors.New("key must not be empty")
+		}
+		key = append([]byte(nil), key...)
+		a.newHash = func() hash.Hash {
+			return hmac.New(sha256.New, key)
+		}
+		return nil
+	})
+}
+
+// HashChainCheckpointEvery sets the number of records between checkpoints. The default is 1000.
+func HashChainCheckpointEvery(records int) HashChainOption {
+	return hashChainOptionsFunc(func(a *HashChain) error {
+		if records <= 0 {
+			return errors.New("records must be positive")
+		}
+		a.checkpointEvery = uint64(records)
+		return nil
+	})
+}
+
+// HashChainAllowRestarts makes VerifyHashChain accept the restart checkpoints of new processes within a log.
+// Restarts are not linked to the previous chain, so records removed before a restart go unnoticed.
+// It has no effect on HashChain.
+func HashChainAllowRestarts() HashChainOption {
+	return hashChainOptionsFunc(func(a *HashChain) error {
+		a.allowRestarts = true
+		return nil
+	})
+}
+
+func NewHashChain(primary Appender, options ...HashChainOption) (a *HashChain, err error) {
+	if primary == nil {
+		return nil, errors.New("primary is required")
+	}
+	a, err = newHashChain(options)
+	if err != nil {
+		return nil, err
+	}
+	a.primary = primary
+	return a, nil
+}
+
+func newHashChain(options []HashChainOption) (a *HashChain, err error) {
+	a = &HashChain{
+		newHash:         sha256.New,
+		checkpointEvery: 1000,
+		now:             time.Now,
+		checkpoint:      true,
+	}
+	for _, option := range options {
+		err = option.apply(a)
+		if err != nil {
+			return nil, err
+		}
+	}
+	a.hash = a.newHash()
+	return a, nil
+}
+
+func (a *HashChain) Write(p []byte, ent zapcore.Entry) (n int, err error) {
+	return a.WriteFields(p, ent, Fields{})
+}
+
+// WriteFields forwards the fields unchanged; they are not covered by the chain.
+func (a *HashChain) WriteFields(p []byte, ent zapcore.Entry, fields Fields) (n int, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.checkpoint {
+		if err = a.writeCheckpoint(); err != nil {
+			return 0, err
+		}
+		a.checkpoint = false
+	}
+	if err = a.writeChained(trimNewline(p), ent, fields, hashChainSeparator); err != nil {
+		return 0, err
+	}
+	a.seq++
+	if a.seq%a.checkpointEvery == 0 {
+		a.checkpoint = true
+	}
+	return len(p), nil
+}
+
+// must be called with a.mu held
+func (a *HashChain) writeCheckpoint() error {
+	now := a.now()
+	buf := bufferpool.Get()
+	defer buf.Free()
+	buf.AppendString(hashChainCheckpoint)
+	buf.AppendString("seq=")
+	buf.AppendUint(a.seq)
+	buf.AppendString(" time=")
+	buf.AppendTime(now, time.RFC3339Nano)
+	buf.AppendString(" prev=")
+	var prev [2 * sha256.Size]byte
+	hex.Encode(prev[:], a.chain[:])
+	_, _ = buf.Write(prev[:])
+	ent := zapcore.Entry{Level: zapcore.InfoLevel, Time: now, Message: "hash chain checkpoint"}
+	return a.writeChained(buf.Bytes(), ent, Fields{}, hashChainCheckpointSeparator)
+}
+
+// writeChained writes payload with its chain value and advances the chain if the write succeeded.
+// must be called with a.mu held
+func (a *HashChain) writeChained(payload []byte, ent zapcore.Entry, fields Fields, separator string) error {
+	var next [sha256.Size]byte
+	hashChainNext(a.hash, a.chain[:], payload, next[:0])
+
+	buf := bufferpool.Get()
+	defer buf.Free()
+	_, _ = buf.Write(payload)
+	buf.AppendString(separator)
+	var encoded [2 * sha256.Size]byte
+	hex.Encode(encoded[:], next[:])
+	_, _ = buf.Write(encoded[:])
+	buf.AppendByte('\n')
+
+	if _, err := writeWithFields(a.primary, buf.Bytes(), ent, fields); err != nil {
+		return err
+	}
+	a.chain = next
+	return nil
+}
+
+// hashChainNext appends the chain value following prev for payload to dst.
+func hashChainNext(h hash.Hash, prev, payload, dst []byte) []byte {
+	h.Reset()
+	_, _ = h.Write(prev)
+	_, _ = h.Write(payload)
+	return h.Sum(dst)
+}
+
+func (a *HashChain) WantsFields() bool {
+	return WantsFields(a.primary)
+}
+
+func (a *HashChain) Sync() error {
+	return a.primary.Sync()
+}
+
+// Synchronized returns true as writes are serialized to keep the chain in order.
+func (a *HashChain) Synchronized() bool {
+	return true
+}
+
+// HashChainError reports the first broken link found by VerifyHashChain.
+type HashChainError struct {
+	// Line is the 1-based line number of the first line of the broken record.
+	Line   int
+	Reason string
+}
+
+func (e *HashChainError) Error() string {
+	return fmt.Sprintf("hash chain broken at line %d: %s", e.Line, e.Reason)
+}
+
+// VerifyHashChain verifies a log written by HashChain and returns a *HashChainError for the first broken link.
+// HashChainHMAC must match the option of the HashChain; the checkpoint interval is not relevant.
+// Restarts of the writing process are reported as broken links unless HashChainAllowRestarts is given.
+//
+// Lines without a chain value are treated as part of the following record, as written for messages
+// containing line breaks.
+func VerifyHashChain(r io.Reader, options ...HashChainOption) error {
+	config, err := newHashChain(options)
+	if err != nil {
+		return err
+	}
+	var (
+		reader    = bufio.NewReader(r)
+		h         = config.hash
+		zero      [sha256.Size]byte
+		chain     []byte
+		next      []byte
+		seq       uint64
+		payload   []byte
+		lineNo    int
+		startLine int
+	)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+		if len(line) > 0 {
+			lineNo++
+			if len(payload) == 0 {
+				startLine = lineNo
+			}
+			line = trimNewline(line)
+			idx, isCheckpoint, expected := parseHashChainValue(line)
+			if expected == nil {
+				payload = append(append(payload, line...), '\n')
+			} else {
+				payload = append(payload, line[:idx]...)
+
+				if isCheckpoint {
+					cpSeq, prev, err := parseHashChainCheckpoint(payload)
+					switch {
+					case err != nil:
+						return &HashChainError{Line: startLine, Reason: err.Error()}
+					case chain == nil:
+						// first record, e.g. of a rotated file
+						seq = cpSeq
+					case cpSeq == 0 && !config.allowRestarts:
+						return &HashChainError{Line: startLine, Reason: "restart checkpoint, the chain of the previous process ends here"}
+					case cpSeq == 0:
+						// restart of the writing process
+						if !bytes.Equal(prev, zero[:]) {
+							return &HashChainError{Line: startLine, Reason: "restart checkpoint with non zero prev"}
+						}
+						seq = 0
+					case cpSeq != seq:
+						return &HashChainError{Line: startLine, Reason: fmt.Sprintf("checkpoint seq %d, expected %d", cpSeq, seq)}
+					case !bytes.Equal(prev, chain):
+						return &HashChainError{Line: startLine, Reason: "checkpoint prev does not match the chain"}
+					}
+					chain = prev
+				} else if chain == nil {
+					chain = zero[:]
+				}
+
+				next = hashChainNext(h, chain, payload, next[:0])
+				if !bytes.Equal(next, expected) {
+					return &HashChainError{Line: startLine, Reason: "chain value mismatch"}
+				}
+				chain = append(chain[:0:0], next...)
+				if !isCheckpoint {
+					seq++
+				}
+				payload = payload[:0]
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+	}
+	if len(payload) > 0 {
+		return &HashChainError{Line: startLine, Reason: "record without chain value"}
+	}
+	return nil
+}
+
+// parseHashChainValue returns the index of the separator, whether it marks a checkpoint and the chain value
+// at the end of line. The chain value is nil if the line does not end with one.
+func parseHashChainValue(line []byte) (idx int, isCheckpoint bool, value []byte) {
+	for _, separator := range []string{hashChainSeparator, hashChainCheckpointSeparator} {
+		idx = len(line) - len(separator) - 2*sha256.Size
+		if idx < 0 || !bytes.Equal(line[idx:idx+len(separator)], []byte(separator)) {
+			continue
+		}
+		value = make([]byte, sha256.Size)
+		if _, err := hex.Decode(value, line[idx+len(separator):]); err != nil {
+			return 0, false, nil
+		}
+		return idx, separator == hashChainCheckpointSeparator, value
+	}
+	return 0, false, nil
+}
+
+// parseHashChainCheckpoint parses "#checkpoint seq=<n> time=<time> prev=<hex>".
+func parseHashChainCheckpoint(payload []byte) (seq uint64, prev []byte, err error) {
+	if !bytes.HasPrefix(payload, []byte(hashChainCheckpoint)) {
+		return 0, nil, errors.New("checkpoint value on a record")
+	}
+	for _, field := range bytes.Fields(payload[len(hashChainCheckpoint):]) {
+		switch {
+		case bytes.HasPrefix(field, []byte("seq=")):
+			seq, err = strconv.ParseUint(string(field[len("seq="):]), 10, 64)
+			if err != nil {
+				return 0, nil, errors.New("invalid checkpoint seq")
+			}
+		case bytes.HasPrefix(field, []byte("prev=")):
+			value := field[len("prev="):]
+			prev = make([]byte, sha256.Size)
+			if len(value) != 2*sha256.Size {
+				return 0, nil, errors.New("invalid checkpoint prev")
+			}
+			if _, err = hex.Decode(prev, value); err != nil {
+				return 0, nil, errors.New("invalid checkpoint prev")
+			}
+		}
+	}
+	if prev == nil {
+		return 0, nil, errors.New("checkpoint without prev")
+	}
+	return seq, prev, nil
+}
@@ -0,0 +1,136 @@
+package zapappender_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/delixfe/zapappender"
+	"github.com/delixfe/zapappender/internal"
+	"go.uber.org/zap/zapcore"
+)
+
+func writeHashChain(t *testing.T, lines []string, options ...zapappender.HashChainOption) string {
+	t.Helper()
+	out := &internal.Buffer{}
+	a, err := zapappender.NewHashChain(zapappender.NewWriter(out), options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range lines {
+		if _, err := a.Write([]byte(line+"\n"), zapcore.Entry{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return out.String()
+}
+
+func TestHashChain_verifies(t *testing.T) {
+	key := zapappender.HashChainHMAC([]byte("secret"))
+	log := writeHashChain(t, []string{"one", "two", "three\nwith stack", "four", "five"},
+		key, zapappender.HashChainCheckpointEvery(2))
+
+	lines := strings.Split(strings.TrimSuffix(log, "\n"), "\n")
+	if len(lines) != 9 {
+		t.Fatalf("expected 3 checkpoints and 6 lines, got\n%s", log)
+	}
+	if !strings.HasPrefix(lines[0], "#checkpoint seq=0 ") || !strings.HasPrefix(lines[3], "#checkpoint seq=2 ") {
+		t.Errorf("unexpected checkpoints\n%s", log)
+	}
+	if err := zapappender.VerifyHashChain(strings.NewReader(log), key); err != nil {
+		t.Errorf("expected a valid chain, got %v", err)
+	}
+
+	// a rotated file starting at a checkpoint verifies on its own
+	rotated := strings.Join(lines[3:], "\n") + "\n"
+	if err := zapappender.VerifyHashChain(strings.NewReader(rotated), key); err != nil {
+		t.Errorf("expected a valid rotated chain, got %v", err)
+	}
+
+	// a restarted process appending to the same file
+	restarted := log + writeHashChain(t, []string{"six"}, key)
+	if err := zapappender.VerifyHashChain(strings.NewReader(restarted), key, zapappender.HashChainAllowRestarts()); err != nil {
+		t.Errorf("expected a valid restarted chain, got %v", err)
+	}
+	var chainErr *zapappender.HashChainError
+	if err := zapappender.VerifyHashChain(strings.NewReader(restarted), key); !errors.As(err, &chainErr) || chainErr.Line != 10 {
+		t.Errorf("expected the restart to be reported without HashChainAllowRestarts, got %v", err)
+	}
+}
+
+func TestHashChain_messageLooksLikeCheckpoint_verifies(t *testing.T) {
+	key := zapappender.HashChainHMAC([]byte("secret"))
+	log := writeHashChain(t, []string{
+		"one",
+		"#checkpoint seq=0 prev=" + strings.Repeat("0", 64),
+		"two",
+	}, key)
+	if err := zapappender.VerifyHashChain(strings.NewReader(log), key); err != nil {
+		t.Errorf("expected a valid chain, got %v\n%s", err, log)
+	}
+}
+
+func TestHashChain_innerLineLooksLikeRecordEnd_reportsBrokenLink(t *testing.T) {
+	// documented limitation: inner lines ending in a chain value cannot be told apart from the end of a record
+	key := zapappender.HashChainHMAC([]byte("secret"))
+	log := writeHashChain(t, []string{"one", "two\tchain=" + strings.Repeat("0", 64) + "\nthree"}, key)
+
+	err := zapappender.VerifyHashChain(strings.NewReader(log), key)
+	var chainErr *zapappender.HashChainError
+	if !errors.As(err, &chainErr) || chainErr.Line != 3 {
+		t.Errorf("expected HashChainError at line 3, got %v\n%s", err, log)
+	}
+}
+
+func TestHashChain_invalidCheckpoint_reportsBrokenLink(t *testing.T) {
+	value := "\tcheckpoint=" + strings.Repeat("0", 64) + "\n"
+	tests := []struct {
+		name string
+		log  string
+	}{
+		{"short", "x" + value},
+		{"empty", value},
+		{"wrong prefix", "#checkpoints seq=0 prev=" + strings.Repeat("0", 64) + value},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := zapappender.VerifyHashChain(strings.NewReader(tt.log))
+			var chainErr *zapappender.HashChainError
+			if !errors.As(err, &chainErr) || chainErr.Line != 1 {
+				t.Errorf("expected HashChainError at line 1, got %v", err)
+			}
+		})
+	}
+}
+
+func TestHashChain_reportsFirstBrokenLink(t *testing.T) {
+	key := zapappender.HashChainHMAC([]byte("secret"))
+	log := writeHashChain(t, []string{"one", "two", "three"}, key)
+
+	tests := []struct {
+		name    string
+		log     string
+		options []zapappender.HashChainOption
+		line    int
+	}{
+		{"modified", strings.Replace(log, "two", "TWO", 1), []zapappender.HashChainOption{key}, 3},
+		{"removed", strings.Join(append(strings.Split(log, "\n")[:2], strings.Split(log, "\n")[3:]...), "\n"),
+			[]zapappender.HashChainOption{key}, 3},
+		{"wrong key", log, []zapappender.HashChainOption{zapappender.HashChainHMAC([]byte("other"))}, 1},
+		{"no key", log, nil, 1},
+		{"unterminated", log + "appended\n", []zapappender.HashChainOption{key}, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := zapappender.VerifyHashChain(bytes.NewBufferString(tt.log), tt.options...)
+			var chainErr *zapappender.HashChainError
+			if !errors.As(err, &chainErr) {
+				t.Fatalf("expected HashChainError, got %v", err)
+			}
+			if chainErr.Line != tt.line {
+				t.Errorf("expected line %d, got %v", tt.line, err)
+			}
+		})
+	}
+}
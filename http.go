@@ -0,0 +1,105 @@
+package zapappender
+
+import (
+	"errors"
+
+	"github.com/delixfe/zapappender/internal/bufferpool"
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+// HTTPFormat defines how the messages of a batch are combined into the request body.
+type HTTPFormat int
+
+const (
+	// HTTPFormatNDJSON sends newline delimited messages with content type application/x-ndjson.
+	HTTPFormatNDJSON HTTPFormat = iota
+	// HTTPFormatJSONArray sends the messages as JSON array; the messages must be JSON encoded.
+	HTTPFormatJSONArray
+)
+
+var _ SynchronizationAwareAppender = &HTTP{}
+
+// HTTP posts batches of messages to a URL.
+//
+// A batch is sent when it is full, when the flush interval elapsed or on Sync.
+// Requests failing with network errors, 429 or 5xx responses are retried.
+// Permanent failures are returned by the Write triggering the flush or by the next Sync.
+type HTTP struct {
+	url       string
+	format    HTTPFormat
+	transport *httpTransport
+	batch     *batcher
+}
+
+func NewHTTP(url string, format HTTPFormat, options ...HTTPOption) (*HTTP, error) {
+	if url == "" {
+		return nil, errors.New("url is required")
+	}
+	if format != HTTPFormatNDJSON && format != HTTPFormatJSONArray {
+		return nil, errors.New("unknown format")
+	}
+	transport, err := newHTTPTransport(options)
+	if err != nil {
+		return nil, err
+	}
+	a := &HTTP{
+		url:       url,
+		format:    format,
+		transport: transport,
+	}
+	a.batch = transport.newBatcher(a.send)
+	return a, nil
+}
+
+func (a *HTTP) Write(p []byte, ent zapcore.Entry) (n int, err error) {
+	buf := bufferpool.Get()
+	_, _ = buf.Write(p)
+	if err = a.batch.add("", buf, ent); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (a *HTTP) send(_ string, items []batchItem) error {
+	body := bufferpool.Get()
+	defer body.Free()
+
+	contentType := "application/x-ndjson"
+	if a.format == HTTPFormatJSONArray {
+		contentType = "application/json"
+		body.AppendByte('[')
+	}
+	for i, item := range items {
+		if a.format == HTTPFormatJSONArray && i > 0 {
+			body.AppendByte(',')
+		}
+		_, _ = body.Write(trimNewline(item.buf.Bytes()))
+		if a.format == HTTPFormatNDJSON {
+			body.AppendByte('\n')
+		}
+	}
+	if a.format == HTTPFormatJSONArray {
+		body.AppendByte(']')
+	}
+
+	_, err := a.transport.post(a.url, contentType, body.Bytes(), nil)
+	if err != nil {
+		return multierr.Append(err, a.transport.forwardFailed(items))
+	}
+	return nil
+}
+
+// Sync sends the pending batch.
+func (a *HTTP) Sync() error {
+	return a.batch.flush()
+}
+
+func (a *HTTP) Synchronized() bool {
+	return true
+}
+
+// Shutdown stops the periodic flushing and sends the pending batch.
+func (a *HTTP) Shutdown() error {
+	return a.batch.stop()
+}
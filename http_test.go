@@ -0,0 +1,128 @@
+package zapappender_test
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/delixfe/zapappender"
+	"github.com/delixfe/zapappender/internal"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestHTTP(t *testing.T) {
+	bodies := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("missing authorization header")
+		}
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected content type %q", r.Header.Get("Content-Type"))
+		}
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		body, _ := io.ReadAll(gz)
+		bodies <- string(body)
+	}))
+	defer server.Close()
+
+	a, err := zapappender.NewHTTP(server.URL, zapappender.HTTPFormatJSONArray,
+		zapappender.HTTPHeader("Authorization", "Bearer secret"),
+		zapappender.HTTPGzip(),
+		zapappender.HTTPBatchSize(2),
+		zapappender.HTTPFlushInterval(time.Hour),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Shutdown()
+
+	for _, msg := range []string{`{"msg":"one"}`, `{"msg":"two"}`, `{"msg":"three"}`} {
+		if _, err := a.Write([]byte(msg+"\n"), zapcore.Entry{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if body := <-bodies; body != `[{"msg":"one"},{"msg":"two"}]` {
+		t.Errorf("unexpected body %q", body)
+	}
+	if err := a.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if body := <-bodies; body != `[{"msg":"three"}]` {
+		t.Errorf("unexpected body %q", body)
+	}
+}
+
+func TestHTTP_retriesHonoringRetryAfter(t *testing.T) {
+	attempts := int32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "one\n" {
+			t.Errorf("unexpected body %q", body)
+		}
+	}))
+	defer server.Close()
+
+	a, err := zapappender.NewHTTP(server.URL, zapappender.HTTPFormatNDJSON,
+		zapappender.HTTPRetries(2, time.Millisecond, 10*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Shutdown()
+
+	_, _ = a.Write([]byte("one\n"), zapcore.Entry{})
+	if err := a.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&attempts) != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestHTTP_permanentFailure_returnsErrAndForwards(t *testing.T) {
+	attempts := int32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		http.Error(w, "bad request", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	failed := &internal.Buffer{}
+	a, err := zapappender.NewHTTP(server.URL, zapappender.HTTPFormatNDJSON,
+		zapappender.HTTPBatchSize(2),
+		zapappender.HTTPOnFailureForwardTo(zapappender.NewWriter(failed)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Shutdown()
+
+	if _, err := a.Write([]byte("one\n"), zapcore.Entry{}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = a.Write([]byte("two\n"), zapcore.Entry{})
+	var statusErr *zapappender.HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected a HTTPStatusError, got %v", err)
+	}
+	if atomic.LoadInt32(&attempts) != 1 {
+		t.Errorf("expected no retries, got %d attempts", attempts)
+	}
+	if failed.String() != "one\ntwo\n" {
+		t.Errorf("unexpected forwarded messages %q", failed.String())
+	}
+}
@@ -0,0 +1,288 @@
+package zapappender
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+// HTTPStatusError is returned if the server responded with an unexpected status code.
+type HTTPStatusError struct {
+	StatusCode int
+	// Body contains the beginning of the response body.
+	Body string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("http status %d: %s", e.StatusCode, e.Body)
+}
+
+// httpTransport holds the settings shared by the appenders sending batches over HTTP.
+type httpTransport struct {
+	// only during construction
+	batchSize     int
+	batchBytes    int
+	flushInterval time.Duration
+	timeout       time.Duration
+
+	// readonly
+	client     *http.Client
+	header     http.Header
+	gzip       bool
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	failed     Appender
+}
+
+// HTTPOption configures the transport of the appenders sending batches over HTTP.
+type HTTPOption interface {
+	apply(*httpTransport) error
+}
+
+type httpOptionsFunc func(*httpTransport) error
+
+func (f httpOptionsFunc) apply(t *httpTransport) error {
+	return f(t)
+}
+
+// HTTPHeader adds a header to every request.
+func HTTPHeader(key, value string) HTTPOption {
+	return httpOptionsFunc(func(t *httpTransport) error {
+		t.header.Add(key, value)
+		return nil
+	})
+}
+
+// HTTPGzip compresses the request bodies with gzip.
+func HTTPGzip() HTTPOption {
+	return httpOptionsFunc(func(t *httpTransport) error {
+		t.gzip = true
+		return nil
+	})
+}
+
+// HTTPClient sets the client used to send the requests.
+// The timeout of client is overridden if HTTPTimeout is used as well.
+func HTTPClient(client *http.Client) HTTPOption {
+	return httpOptionsFunc(func(t *httpTransport) error {
+		if client == nil {
+			return errors.New("client must not be nil")
+		}
+		t.client = client
+		return nil
+	})
+}
+
+// HTTPTimeout limits the duration of a single request. The default is 10 seconds.
+func HTTPTimeout(timeout time.Duration) HTTPOption {
+	return httpOptionsFunc(func(t *httpTransport) error {
+		if timeout <= time.Duration(0) {
+			return errors.New("timeout must be positive")
+		}
+		t.timeout = timeout
+		return nil
+	})
+}
+
+// HTTPBatchSize sets the maximum number of messages per request. The default is 100.
+func HTTPBatchSize(size int) HTTPOption {
+	return httpOptionsFunc(func(t *httpTransport) error {
+		if size < 1 {
+			return errors.New("size must be at least 1")
+		}
+		t.batchSize = size
+		return nil
+	})
+}
+
+// HTTPBatchBytes sets the maximum size of the messages per request. The default is 1 MiB.
+func HTTPBatchBytes(size int) HTTPOption {
+	return httpOptionsFunc(func(t *httpTransport) error {
+		if size < 1 {
+			return errors.New("size must be at least 1")
+		}
+		t.batchBytes = size
+		return nil
+	})
+}
+
+// HTTPFlushInterval sets the interval in which incomplete batches are sent. The default is 1 second.
+func HTTPFlushInterval(interval time.Duration) HTTPOption {
+	return httpOptionsFunc(func(t *httpTransport) error {
+		if interval <= time.Duration(0) {
+			return errors.New("interval must be positive")
+		}
+		t.flushInterval = interval
+		return nil
+	})
+}
+
+// HTTPRetries sets how often a request is retried on network errors, 429 and 5xx responses
+// and the bounds of the exponential backoff between the attempts. The default is 3 retries
+// with a backoff between 100ms and 5s.
+// A Retry-After header of the response is honored up to maxBackoff.
+func HTTPRetries(maxRetries int, minBackoff, maxBackoff time.Duration) HTTPOption {
+	return httpOptionsFunc(func(t *httpTransport) error {
+		if maxRetries < 0 {
+			return errors.New("maxRetries must not be negative")
+		}
+		if minBackoff <= 0 || maxBackoff < minBackoff {
+			return errors.New("backoff must be positive and minBackoff must not exceed maxBackoff")
+		}
+		t.maxRetries = maxRetries
+		t.minBackoff = minBackoff
+		t.maxBackoff = maxBackoff
+		return nil
+	})
+}
+
+// HTTPOnFailureForwardTo forwards all messages of a batch that could not be sent to failed.
+// The error is still returned. failed is wrapped in a Synchronizing appender.
+//
+// Prefer this over a Fallback around the appender: the error is returned by the Write
+// triggering the flush, so a Fallback only receives that single message.
+func HTTPOnFailureForwardTo(failed Appender) HTTPOption {
+	return httpOptionsFunc(func(t *httpTransport) error {
+		if failed == nil {
+			return errors.New("failed must not be nil")
+		}
+		t.failed = NewSynchronizing(failed)
+		return nil
+	})
+}
+
+func newHTTPTransport(options []HTTPOption) (*httpTransport, error) {
+	t := &httpTransport{
+		batchSize:     100,
+		batchBytes:    1024 * 1024,
+		flushInterval: time.Second,
+		timeout:       10 * time.Second,
+		client:        http.DefaultClient,
+		header:        make(http.Header),
+		maxRetries:    3,
+		minBackoff:    100 * time.Millisecond,
+		maxBackoff:    5 * time.Second,
+		failed:        NewDiscard(),
+	}
+	for _, option := range options {
+		if err := option.apply(t); err != nil {
+			return nil, err
+		}
+	}
+	client := *t.client
+	client.Timeout = t.timeout
+	t.client = &client
+	return t, nil
+}
+
+func (t *httpTransport) newBatcher(flushFn batchFlushFn) *batcher {
+	return newBatcher(t.batchSize, t.batchBytes, t.flushInterval, flushFn)
+}
+
+// forwardFailed writes the messages of items to the failed appender.
+func (t *httpTransport) forwardFailed(items []batchItem) (err error) {
+	for _, item := range items {
+		_, writeErr := t.failed.Write(item.buf.Bytes(), item.ent)
+		err = multierr.Append(err, writeErr)
+	}
+	return err
+}
+
+// post sends body and returns the response body of a 2xx response.
+// Requests are retried on network errors, 429 and 5xx responses.
+func (t *httpTransport) post(url, contentType string, body []byte, header http.Header) ([]byte, error) {
+	if t.gzip {
+		var compressed bytes.Buffer
+		w := gzip.NewWriter(&compressed)
+		if _, err := w.Write(body); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		body = compressed.Bytes()
+	}
+
+	backoff := t.minBackoff
+	for attempt := 0; ; attempt++ {
+		respBody, retryAfter, err := t.postOnce(url, contentType, body, header)
+		if err == nil || retryAfter < 0 || attempt >= t.maxRetries {
+			return respBody, err
+		}
+		wait := backoff
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		if wait > t.maxBackoff {
+			wait = t.maxBackoff
+		}
+		time.Sleep(wait)
+		backoff *= 2
+	}
+}
+
+// postOnce returns a negative retryAfter if the request must not be retried.
+func (t *httpTransport) postOnce(url, contentType string, body []byte, header http.Header) (respBody []byte, retryAfter time.Duration, err error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, -1, err
+	}
+	for key, values := range t.header {
+		req.Header[key] = values
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", contentType)
+	if t.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	respBody, err = io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return respBody, 0, nil
+	}
+
+	const maxErrorBody = 1024
+	errBody := respBody
+	if len(errBody) > maxErrorBody {
+		errBody = errBody[:maxErrorBody]
+	}
+	err = &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(errBody)}
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		return respBody, -1, err
+	}
+	return respBody, parseRetryAfter(resp.Header.Get("Retry-After")), err
+}
+
+// parseRetryAfter parses delay-seconds or an HTTP-date, it returns 0 if the value is missing or invalid.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
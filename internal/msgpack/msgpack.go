@@ -0,0 +1,294 @@
+// Package msgpack implements the subset of the MessagePack format
+// required by the Fluentd forward protocol.
+package msgpack
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"go.uber.org/zap/buffer"
+)
+
+// AppendArrayHeader appends the header of an array with n elements.
+func AppendArrayHeader(buf *buffer.Buffer, n int) {
+	switch {
+	case n < 16:
+		buf.AppendByte(0x90 | byte(n))
+	case n <= math.MaxUint16:
+		buf.AppendByte(0xdc)
+		appendUint16(buf, uint16(n))
+	default:
+		buf.AppendByte(0xdd)
+		appendUint32(buf, uint32(n))
+	}
+}
+
+// AppendMapHeader appends the header of a map with n key value pairs.
+func AppendMapHeader(buf *buffer.Buffer, n int) {
+	switch {
+	case n < 16:
+		buf.AppendByte(0x80 | byte(n))
+	case n <= math.MaxUint16:
+		buf.AppendByte(0xde)
+		appendUint16(buf, uint16(n))
+	default:
+		buf.AppendByte(0xdf)
+		appendUint32(buf, uint32(n))
+	}
+}
+
+// AppendStringHeader appends the header of a string with n bytes.
+func AppendStringHeader(buf *buffer.Buffer, n int) {
+	switch {
+	case n < 32:
+		buf.AppendByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		buf.AppendByte(0xd9)
+		buf.AppendByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.AppendByte(0xda)
+		appendUint16(buf, uint16(n))
+	default:
+		buf.AppendByte(0xdb)
+		appendUint32(buf, uint32(n))
+	}
+}
+
+// AppendString appends s as string.
+func AppendString(buf *buffer.Buffer, s string) {
+	AppendStringHeader(buf, len(s))
+	buf.AppendString(s)
+}
+
+// AppendStringBytes appends s as string.
+func AppendStringBytes(buf *buffer.Buffer, s []byte) {
+	AppendStringHeader(buf, len(s))
+	_, _ = buf.Write(s)
+}
+
+// AppendBinaryHeader appends the header of a binary with n bytes.
+func AppendBinaryHeader(buf *buffer.Buffer, n int) {
+	switch {
+	case n <= math.MaxUint8:
+		buf.AppendByte(0xc4)
+		buf.AppendByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.AppendByte(0xc5)
+		appendUint16(buf, uint16(n))
+	default:
+		buf.AppendByte(0xc6)
+		appendUint32(buf, uint32(n))
+	}
+}
+
+// AppendUint appends an unsigned integer in the most compact form.
+func AppendUint(buf *buffer.Buffer, v uint64) {
+	switch {
+	case v < 128:
+		buf.AppendByte(byte(v))
+	case v <= math.MaxUint8:
+		buf.AppendByte(0xcc)
+		buf.AppendByte(byte(v))
+	case v <= math.MaxUint16:
+		buf.AppendByte(0xcd)
+		appendUint16(buf, uint16(v))
+	case v <= math.MaxUint32:
+		buf.AppendByte(0xce)
+		appendUint32(buf, uint32(v))
+	default:
+		buf.AppendByte(0xcf)
+		appendUint32(buf, uint32(v>>32))
+		appendUint32(buf, uint32(v))
+	}
+}
+
+// AppendEventTime appends t as Fluentd EventTime extension type.
+func AppendEventTime(buf *buffer.Buffer, t time.Time) {
+	buf.AppendByte(0xd7) // fixext 8
+	buf.AppendByte(0x00) // EventTime
+	appendUint32(buf, uint32(t.Unix()))
+	appendUint32(buf, uint32(t.Nanosecond()))
+}
+
+func appendUint16(buf *buffer.Buffer, v uint16) {
+	buf.AppendByte(byte(v >> 8))
+	buf.AppendByte(byte(v))
+}
+
+func appendUint32(buf *buffer.Buffer, v uint32) {
+	buf.AppendByte(byte(v >> 24))
+	buf.AppendByte(byte(v >> 16))
+	buf.AppendByte(byte(v >> 8))
+	buf.AppendByte(byte(v))
+}
+
+// Ext is a decoded extension type.
+type Ext struct {
+	Type int8
+	Data []byte
+}
+
+// Decoder reads MessagePack values from a stream.
+//
+// Strings are decoded to string, binaries to []byte, integers to int64 or uint64,
+// arrays to []interface{} and maps to map[interface{}]interface{}.
+type Decoder struct {
+	r *bufio.Reader
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the next value.
+func (d *Decoder) Decode() (interface{}, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xf0 == 0x80:
+		return d.decodeMap(int(b & 0x0f))
+	case b&0xf0 == 0x90:
+		return d.decodeArray(int(b & 0x0f))
+	case b&0xe0 == 0xa0:
+		return d.readString(int(b & 0x1f))
+	}
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.readLength(b - 0xc4)
+		if err != nil {
+			return nil, err
+		}
+		return d.readBytes(n)
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.readLength(b - 0xc7)
+		if err != nil {
+			return nil, err
+		}
+		return d.readExt(n)
+	case 0xca:
+		v, err := d.readUint(4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case 0xcb:
+		v, err := d.readUint(8)
+		return math.Float64frombits(v), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return d.readUint(1 << (b - 0xcc))
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (b - 0xd0)
+		v, err := d.readUint(size)
+		shift := uint(64 - 8*size)
+		return int64(v<<shift) >> shift, err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.readExt(1 << (b - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.readLength(b - 0xd9)
+		if err != nil {
+			return nil, err
+		}
+		return d.readString(n)
+	case 0xdc, 0xdd:
+		n, err := d.readLength(b - 0xdc + 1)
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeArray(n)
+	case 0xde, 0xdf:
+		n, err := d.readLength(b - 0xde + 1)
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeMap(n)
+	}
+	return nil, fmt.Errorf("msgpack: unsupported format 0x%x", b)
+}
+
+// readLength reads a length of 1, 2 or 4 bytes for the exponents 0, 1 and 2.
+func (d *Decoder) readLength(exponent byte) (int, error) {
+	v, err := d.readUint(1 << exponent)
+	if err != nil {
+		return 0, err
+	}
+	if v > math.MaxInt32 {
+		return 0, errors.New("msgpack: length overflow")
+	}
+	return int(v), nil
+}
+
+func (d *Decoder) readUint(size int) (uint64, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(d.r, b[8-size:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(b[:]), nil
+}
+
+func (d *Decoder) readBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := io.ReadFull(d.r, b)
+	return b, err
+}
+
+func (d *Decoder) readString(n int) (string, error) {
+	b, err := d.readBytes(n)
+	return string(b), err
+}
+
+func (d *Decoder) readExt(n int) (Ext, error) {
+	t, err := d.r.ReadByte()
+	if err != nil {
+		return Ext{}, err
+	}
+	data, err := d.readBytes(n)
+	return Ext{Type: int8(t), Data: data}, err
+}
+
+func (d *Decoder) decodeArray(n int) ([]interface{}, error) {
+	values := make([]interface{}, n)
+	for i := range values {
+		v, err := d.Decode()
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func (d *Decoder) decodeMap(n int) (map[interface{}]interface{}, error) {
+	values := make(map[interface{}]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := d.Decode()
+		if err != nil {
+			return nil, err
+		}
+		switch key := k.(type) {
+		case []byte:
+			k = string(key)
+		case []interface{}, map[interface{}]interface{}:
+			return nil, errors.New("msgpack: unsupported map key")
+		}
+		v, err := d.Decode()
+		if err != nil {
+			return nil, err
+		}
+		values[k] = v
+	}
+	return values, nil
+}
@@ -0,0 +1,111 @@
+package msgpack
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/buffer"
+)
+
+func TestAppend_typeBoundaries(t *testing.T) {
+	tests := []struct {
+		name   string
+		append func(buf *buffer.Buffer)
+		header []byte
+		want   interface{}
+	}{
+		{name: "fixstr empty", append: func(buf *buffer.Buffer) { AppendString(buf, "") }, header: []byte{0xa0}, want: ""},
+		{name: "fixstr max", append: func(buf *buffer.Buffer) { AppendString(buf, strings.Repeat("a", 31)) }, header: []byte{0xbf}, want: strings.Repeat("a", 31)},
+		{name: "str8 min", append: func(buf *buffer.Buffer) { AppendString(buf, strings.Repeat("a", 32)) }, header: []byte{0xd9, 32}, want: strings.Repeat("a", 32)},
+		{name: "str8 max", append: func(buf *buffer.Buffer) { AppendString(buf, strings.Repeat("a", 255)) }, header: []byte{0xd9, 0xff}, want: strings.Repeat("a", 255)},
+		{name: "str16 min", append: func(buf *buffer.Buffer) { AppendString(buf, strings.Repeat("a", 256)) }, header: []byte{0xda, 0x01, 0x00}, want: strings.Repeat("a", 256)},
+		{name: "str16 max", append: func(buf *buffer.Buffer) { AppendString(buf, strings.Repeat("a", math.MaxUint16)) }, header: []byte{0xda, 0xff, 0xff}, want: strings.Repeat("a", math.MaxUint16)},
+		{name: "str32 min", append: func(buf *buffer.Buffer) { AppendString(buf, strings.Repeat("a", math.MaxUint16+1)) }, header: []byte{0xdb, 0x00, 0x01, 0x00, 0x00}, want: strings.Repeat("a", math.MaxUint16+1)},
+		{name: "fixmap max", append: func(buf *buffer.Buffer) { appendMap(buf, 15) }, header: []byte{0x8f}, want: decodedMap(15)},
+		{name: "map16 min", append: func(buf *buffer.Buffer) { appendMap(buf, 16) }, header: []byte{0xde, 0x00, 0x10}, want: decodedMap(16)},
+		{name: "fixarray max", append: func(buf *buffer.Buffer) { appendArray(buf, 15) }, header: []byte{0x9f}, want: decodedArray(15)},
+		{name: "array16 min", append: func(buf *buffer.Buffer) { appendArray(buf, 16) }, header: []byte{0xdc, 0x00, 0x10}, want: decodedArray(16)},
+		{name: "positive fixint max", append: func(buf *buffer.Buffer) { AppendUint(buf, 127) }, header: []byte{0x7f}, want: int64(127)},
+		{name: "uint8 min", append: func(buf *buffer.Buffer) { AppendUint(buf, 128) }, header: []byte{0xcc, 0x80}, want: uint64(128)},
+		{name: "uint8 max", append: func(buf *buffer.Buffer) { AppendUint(buf, math.MaxUint8) }, header: []byte{0xcc, 0xff}, want: uint64(math.MaxUint8)},
+		{name: "uint16 min", append: func(buf *buffer.Buffer) { AppendUint(buf, math.MaxUint8+1) }, header: []byte{0xcd, 0x01, 0x00}, want: uint64(math.MaxUint8 + 1)},
+		{name: "uint16 max", append: func(buf *buffer.Buffer) { AppendUint(buf, math.MaxUint16) }, header: []byte{0xcd, 0xff, 0xff}, want: uint64(math.MaxUint16)},
+		{name: "uint32 min", append: func(buf *buffer.Buffer) { AppendUint(buf, math.MaxUint16+1) }, header: []byte{0xce, 0x00, 0x01, 0x00, 0x00}, want: uint64(math.MaxUint16 + 1)},
+		{name: "uint32 max", append: func(buf *buffer.Buffer) { AppendUint(buf, math.MaxUint32) }, header: []byte{0xce, 0xff, 0xff, 0xff, 0xff}, want: uint64(math.MaxUint32)},
+		{name: "uint64 min", append: func(buf *buffer.Buffer) { AppendUint(buf, math.MaxUint32+1) }, header: []byte{0xcf, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}, want: uint64(math.MaxUint32 + 1)},
+		{name: "uint64 max", append: func(buf *buffer.Buffer) { AppendUint(buf, math.MaxUint64) }, header: []byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, want: uint64(math.MaxUint64)},
+		{name: "bin8 max", append: func(buf *buffer.Buffer) { appendBinary(buf, math.MaxUint8) }, header: []byte{0xc4, 0xff}, want: make([]byte, math.MaxUint8)},
+		{name: "bin16 min", append: func(buf *buffer.Buffer) { appendBinary(buf, math.MaxUint8+1) }, header: []byte{0xc5, 0x01, 0x00}, want: make([]byte, math.MaxUint8+1)},
+		{
+			name:   "ext event time",
+			append: func(buf *buffer.Buffer) { AppendEventTime(buf, time.Unix(1600000000, 123456789)) },
+			header: []byte{0xd7, 0x00, 0x5f, 0x5e, 0x10, 0x00, 0x07, 0x5b, 0xcd, 0x15},
+			want:   Ext{Type: 0, Data: []byte{0x5f, 0x5e, 0x10, 0x00, 0x07, 0x5b, 0xcd, 0x15}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &buffer.Buffer{}
+			tt.append(buf)
+			if !bytes.HasPrefix(buf.Bytes(), tt.header) {
+				t.Errorf("expected header % x, got % x", tt.header, head(buf.Bytes(), len(tt.header)))
+			}
+
+			d := NewDecoder(bytes.NewReader(buf.Bytes()))
+			got, err := d.Decode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tt.want, got) {
+				t.Errorf("decoded value of type %T does not match", got)
+			}
+		})
+	}
+}
+
+func appendMap(buf *buffer.Buffer, n int) {
+	AppendMapHeader(buf, n)
+	for i := 0; i < n; i++ {
+		AppendUint(buf, uint64(i))
+		AppendString(buf, "v")
+	}
+}
+
+func decodedMap(n int) map[interface{}]interface{} {
+	m := make(map[interface{}]interface{}, n)
+	for i := 0; i < n; i++ {
+		m[int64(i)] = "v"
+	}
+	return m
+}
+
+func appendArray(buf *buffer.Buffer, n int) {
+	AppendArrayHeader(buf, n)
+	for i := 0; i < n; i++ {
+		AppendUint(buf, uint64(i))
+	}
+}
+
+func decodedArray(n int) []interface{} {
+	values := make([]interface{}, n)
+	for i := range values {
+		values[i] = int64(i)
+	}
+	return values
+}
+
+func appendBinary(buf *buffer.Buffer, n int) {
+	AppendBinaryHeader(buf, n)
+	_, _ = buf.Write(make([]byte, n))
+}
+
+func head(b []byte, n int) []byte {
+	if len(b) < n {
+		return b
+	}
+	return b[:n]
+}
@@ -0,0 +1,73 @@
+// Package protowire implements the subset of the protocol buffers wire format
+// required to encode the push requests of Loki and OTLP.
+package protowire
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+const (
+	VarintType  = 0
+	Fixed64Type = 1
+	BytesType   = 2
+	Fixed32Type = 5
+)
+
+// AppendTag appends the key of field num with the wire type typ.
+func AppendTag(b []byte, num int, typ int) []byte {
+	return AppendVarint(b, uint64(num)<<3|uint64(typ))
+}
+
+func AppendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+// AppendVarintField appends a varint field, zero values are omitted.
+func AppendVarintField(b []byte, num int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = AppendTag(b, num, VarintType)
+	return AppendVarint(b, v)
+}
+
+// AppendFixed64Field appends a fixed64 field, zero values are omitted.
+func AppendFixed64Field(b []byte, num int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = AppendTag(b, num, Fixed64Type)
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+// AppendDoubleField appends a double field, zero values are omitted.
+func AppendDoubleField(b []byte, num int, v float64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = AppendTag(b, num, Fixed64Type)
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+	return append(b, buf[:]...)
+}
+
+// AppendBytesField appends a length delimited field, it is also used for strings and embedded messages.
+func AppendBytesField(b []byte, num int, v []byte) []byte {
+	b = AppendTag(b, num, BytesType)
+	b = AppendVarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// AppendStringField appends a string field.
+func AppendStringField(b []byte, num int, v string) []byte {
+	b = AppendTag(b, num, BytesType)
+	b = AppendVarint(b, uint64(len(v)))
+	return append(b, v...)
+}
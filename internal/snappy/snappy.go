@@ -0,0 +1,37 @@
+// Package snappy implements an encoder for the snappy block format.
+//
+// The encoder emits literals only. The output is valid snappy and decodable by any
+// snappy implementation, but it is not compressed.
+package snappy
+
+const maxLiteral = 1 << 16
+
+// Encode appends the encoded src to dst.
+func Encode(dst, src []byte) []byte {
+	// preamble: uncompressed length as varint
+	n := uint64(len(src))
+	for n >= 0x80 {
+		dst = append(dst, byte(n)|0x80)
+		n >>= 7
+	}
+	dst = append(dst, byte(n))
+
+	for len(src) > 0 {
+		chunk := src
+		if len(chunk) > maxLiteral {
+			chunk = chunk[:maxLiteral]
+		}
+		length := len(chunk) - 1
+		switch {
+		case length < 60:
+			dst = append(dst, byte(length)<<2)
+		case length < 1<<8:
+			dst = append(dst, 60<<2, byte(length))
+		default:
+			dst = append(dst, 61<<2, byte(length), byte(length>>8))
+		}
+		dst = append(dst, chunk...)
+		src = src[len(chunk):]
+	}
+	return dst
+}
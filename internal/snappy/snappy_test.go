@@ -0,0 +1,54 @@
+package snappy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// decode is a minimal decoder for literal only blocks
+func decode(src []byte) ([]byte, error) {
+	n, read := binary.Uvarint(src)
+	if read <= 0 {
+		return nil, errors.New("invalid preamble")
+	}
+	src = src[read:]
+	var dst []byte
+	for len(src) > 0 {
+		tag := src[0]
+		if tag&0x03 != 0 {
+			return nil, errors.New("unexpected copy")
+		}
+		length := int(tag >> 2)
+		src = src[1:]
+		switch length {
+		case 60:
+			length = int(src[0])
+			src = src[1:]
+		case 61:
+			length = int(src[0]) | int(src[1])<<8
+			src = src[2:]
+		}
+		length++
+		dst = append(dst, src[:length]...)
+		src = src[length:]
+	}
+	if uint64(len(dst)) != n {
+		return nil, errors.New("length mismatch")
+	}
+	return dst, nil
+}
+
+func TestEncode(t *testing.T) {
+	for _, size := range []int{0, 1, 59, 60, 61, 255, 256, 257, maxLiteral, maxLiteral + 1, 3*maxLiteral + 7} {
+		src := bytes.Repeat([]byte("abcdefg"), size/7+1)[:size]
+		got, err := decode(Encode(nil, src))
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !bytes.Equal(got, src) {
+			t.Errorf("size %d: roundtrip failed", size)
+		}
+	}
+}
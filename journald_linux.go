@@ -0,0 +1,296 @@
+//go:build linux
+// +build linux
+
+package zapappender
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/delixfe/zapappender/internal/bufferpool"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+const journaldSocketPath = "/run/systemd/journal/socket"
+
+var _ SynchronizationAwareAppender = &Journald{}
+
+// Journald sends messages to systemd-journald using its native protocol.
+//
+// The encoded message is sent as MESSAGE field. The entry is mapped to the fields
+// PRIORITY, SYSLOG_IDENTIFIER, CODE_FILE, CODE_LINE, CODE_FUNC and LOGGER.
+// Messages too large for a datagram are passed as sealed memfd or, if that is not available,
+// as deleted temporary file.
+type Journald struct {
+	// readonly
+	addr       *net.UnixAddr
+	identifier string
+	fields     []byte // pre-encoded static fields
+
+	// state
+	mu   sync.Mutex
+	conn *net.UnixConn
+}
+
+type JournaldOption interface {
+	apply(*Journald) error
+}
+
+type journaldOptionsFunc func(*Journald) error
+
+func (f journaldOptionsFunc) apply(a *Journald) error {
+	return f(a)
+}
+
+// JournaldSocketPath sets the path of the journald socket. The default is /run/systemd/journal/socket.
+func JournaldSocketPath(path string) JournaldOption {
+	return journaldOptionsFunc(func(a *Journald) error {
+		if path == "" {
+			return errors.New("path must not be empty")
+		}
+		a.addr = &net.UnixAddr{Name: path, Net: "unixgram"}
+		return nil
+	})
+}
+
+// JournaldIdentifier sets the SYSLOG_IDENTIFIER. The default is the name of the executable.
+func JournaldIdentifier(identifier string) JournaldOption {
+	return journaldOptionsFunc(func(a *Journald) error {
+		a.identifier = identifier
+		return nil
+	})
+}
+
+// JournaldFields adds static fields to every message.
+// Field names must consist of uppercase letters, digits and underscores and must not start with an underscore.
+func JournaldFields(fields map[string]string) JournaldOption {
+	return journaldOptionsFunc(func(a *Journald) error {
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			if !validJournaldFieldName(name) {
+				return fmt.Errorf("invalid journald field name %q", name)
+			}
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		buf := bufferpool.Get()
+		defer buf.Free()
+		for _, name := range names {
+			appendJournaldField(buf, name, fields[name])
+		}
+		a.fields = append([]byte(nil), buf.Bytes()...)
+		return nil
+	})
+}
+
+func validJournaldFieldName(name string) bool {
+	if name == "" || len(name) > 64 || name[0] == '_' || (name[0] >= '0' && name[0] <= '9') {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+func NewJournald(options ...JournaldOption) (a *Journald, err error) {
+	a = &Journald{
+		addr:       &net.UnixAddr{Name: journaldSocketPath, Net: "unixgram"},
+		identifier: filepath.Base(os.Args[0]),
+	}
+	for _, option := range options {
+		err = option.apply(a)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+func (a *Journald) Write(p []byte, ent zapcore.Entry) (n int, err error) {
+	buf := bufferpool.Get()
+	defer buf.Free()
+
+	appendJournaldFieldBytes(buf, "MESSAGE", trimNewline(p))
+	buf.AppendString("PRIORITY=")
+	buf.AppendInt(int64(syslogSeverity(ent.Level)))
+	buf.AppendByte('\n')
+	if a.identifier != "" {
+		appendJournaldField(buf, "SYSLOG_IDENTIFIER", a.identifier)
+	}
+	if ent.LoggerName != "" {
+		appendJournaldField(buf, "LOGGER", ent.LoggerName)
+	}
+	if ent.Caller.Defined {
+		appendJournaldField(buf, "CODE_FILE", ent.Caller.File)
+		buf.AppendString("CODE_LINE=")
+		buf.AppendInt(int64(ent.Caller.Line))
+		buf.AppendByte('\n')
+		if ent.Caller.Function != "" {
+			appendJournaldField(buf, "CODE_FUNC", ent.Caller.Function)
+		}
+	}
+	_, _ = buf.Write(a.fields)
+
+	if err = a.send(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (a *Journald) send(data []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.conn == nil {
+		conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+		if err != nil {
+			return err
+		}
+		a.conn = conn
+	}
+	_, _, err := a.conn.WriteMsgUnix(data, nil, a.addr)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, syscall.EMSGSIZE) && !errors.Is(err, syscall.ENOBUFS) {
+		return err
+	}
+	return a.sendLarge(data)
+}
+
+// sendLarge passes data as file descriptor.
+// must be called with the lock held
+func (a *Journald) sendLarge(data []byte) error {
+	file, err := journaldMemfd(data)
+	if err != nil {
+		file, err = journaldTempFile(data)
+		if err != nil {
+			return err
+		}
+	}
+	defer file.Close()
+	_, _, err = a.conn.WriteMsgUnix(nil, syscall.UnixRights(int(file.Fd())), a.addr)
+	return err
+}
+
+// journaldMemfd writes data into a sealed memfd.
+func journaldMemfd(data []byte) (*os.File, error) {
+	if sysMemfdCreate == 0 {
+		return nil, errors.New("memfd_create not supported")
+	}
+	name, err := syscall.BytePtrFromString("journal-message")
+	if err != nil {
+		return nil, err
+	}
+	const (
+		mfdCloexec      = 0x1
+		mfdAllowSealing = 0x2
+		fAddSeals       = 1033
+		fSealAll        = 0x1 | 0x2 | 0x4 | 0x8 // seal, shrink, grow, write
+	)
+	fd, _, errno := syscall.Syscall(uintptr(sysMemfdCreate), uintptr(unsafe.Pointer(name)), mfdCloexec|mfdAllowSealing, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	file := os.NewFile(fd, "journal-message")
+	if _, err = file.Write(data); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, _, errno = syscall.Syscall(syscall.SYS_FCNTL, fd, fAddSeals, fSealAll); errno != 0 {
+		file.Close()
+		return nil, errno
+	}
+	return file, nil
+}
+
+// journaldTempFile writes data into a deleted temporary file.
+func journaldTempFile(data []byte) (*os.File, error) {
+	file, err := os.CreateTemp("/dev/shm", "journal.*")
+	if err != nil {
+		file, err = os.CreateTemp("", "journal.*")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err = os.Remove(file.Name()); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err = file.Write(data); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+func appendJournaldField(buf *buffer.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		buf.AppendString(name)
+		buf.AppendByte('=')
+		buf.AppendString(value)
+		buf.AppendByte('\n')
+		return
+	}
+	appendJournaldBinaryFieldHeader(buf, name, len(value))
+	buf.AppendString(value)
+	buf.AppendByte('\n')
+}
+
+func appendJournaldFieldBytes(buf *buffer.Buffer, name string, value []byte) {
+	for _, c := range value {
+		if c == '\n' {
+			appendJournaldBinaryFieldHeader(buf, name, len(value))
+			_, _ = buf.Write(value)
+			buf.AppendByte('\n')
+			return
+		}
+	}
+	buf.AppendString(name)
+	buf.AppendByte('=')
+	_, _ = buf.Write(value)
+	buf.AppendByte('\n')
+}
+
+// appendJournaldBinaryFieldHeader appends the name followed by the length as little endian uint64.
+func appendJournaldBinaryFieldHeader(buf *buffer.Buffer, name string, length int) {
+	buf.AppendString(name)
+	buf.AppendByte('\n')
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(length))
+	_, _ = buf.Write(size[:])
+}
+
+func (a *Journald) Sync() error {
+	return nil
+}
+
+func (a *Journald) Synchronized() bool {
+	return true
+}
+
+// Close closes the socket.
+func (a *Journald) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.conn == nil {
+		return nil
+	}
+	err := a.conn.Close()
+	a.conn = nil
+	return err
+}
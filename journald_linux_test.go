@@ -0,0 +1,119 @@
+//go:build linux
+// +build linux
+
+package zapappender_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/delixfe/zapappender"
+	"go.uber.org/zap/zapcore"
+)
+
+func listenJournald(t *testing.T) (string, *net.UnixConn) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "journal.socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return path, conn
+}
+
+func TestJournald(t *testing.T) {
+	path, listener := listenJournald(t)
+	a, err := zapappender.NewJournald(
+		zapappender.JournaldSocketPath(path),
+		zapappender.JournaldIdentifier("shop"),
+		zapappender.JournaldFields(map[string]string{"ENV": "prod"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	ent := zapcore.Entry{
+		Level:      zapcore.WarnLevel,
+		LoggerName: "http",
+		Caller:     zapcore.EntryCaller{Defined: true, File: "/src/handler.go", Line: 42, Function: "main.handle"},
+	}
+	if _, err := a.Write([]byte("line one\nline two\n"), ent); err != nil {
+		t.Fatal(err)
+	}
+
+	datagram := make([]byte, 4096)
+	n, err := listener.Read(datagram)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected bytes.Buffer
+	expected.WriteString("MESSAGE\n")
+	_ = binary.Write(&expected, binary.LittleEndian, uint64(len("line one\nline two")))
+	expected.WriteString("line one\nline two\n")
+	expected.WriteString("PRIORITY=4\nSYSLOG_IDENTIFIER=shop\nLOGGER=http\n")
+	expected.WriteString("CODE_FILE=/src/handler.go\nCODE_LINE=42\nCODE_FUNC=main.handle\nENV=prod\n")
+	if !bytes.Equal(datagram[:n], expected.Bytes()) {
+		t.Errorf("expected\n%q\ngot\n%q", expected.String(), datagram[:n])
+	}
+}
+
+func TestJournald_largeMessage_passesFileDescriptor(t *testing.T) {
+	path, listener := listenJournald(t)
+	a, err := zapappender.NewJournald(zapappender.JournaldSocketPath(path), zapappender.JournaldIdentifier(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	msg := strings.Repeat("x", 4*1024*1024)
+	if _, err := a.Write([]byte(msg), zapcore.Entry{Level: zapcore.InfoLevel}); err != nil {
+		t.Fatal(err)
+	}
+
+	oob := make([]byte, syscall.CmsgSpace(4))
+	n, oobn, _, _, err := listener.ReadMsgUnix(nil, oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatalf("expected an empty datagram, got %d bytes", n)
+	}
+	messages, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(messages) != 1 {
+		t.Fatalf("expected one control message: %v", err)
+	}
+	fds, err := syscall.ParseUnixRights(&messages[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatalf("expected one file descriptor: %v", err)
+	}
+	file := os.NewFile(uintptr(fds[0]), "journal")
+	defer file.Close()
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "MESSAGE=" + msg + "\nPRIORITY=6\n"
+	if string(content) != expected {
+		t.Errorf("unexpected content of %d bytes", len(content))
+	}
+}
+
+func TestJournaldFields_invalidName_returnsErr(t *testing.T) {
+	for _, name := range []string{"", "_PID", "lower", "1ST", "WITH-DASH"} {
+		if _, err := zapappender.NewJournald(zapappender.JournaldFields(map[string]string{name: "x"})); err == nil {
+			t.Errorf("expected an error for %q", name)
+		}
+	}
+}
@@ -0,0 +1,4 @@
+package zapappender
+
+// the syscall package does not define SYS_MEMFD_CREATE for amd64
+const sysMemfdCreate = 319
@@ -0,0 +1,3 @@
+package zapappender
+
+const sysMemfdCreate = 279
@@ -0,0 +1,7 @@
+//go:build linux && !amd64 && !arm64
+// +build linux,!amd64,!arm64
+
+package zapappender
+
+// memfd is not used on other architectures, large messages are passed as temporary file
+const sysMemfdCreate = 0
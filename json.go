@@ -0,0 +1,87 @@
+package zapappender
+
+import (
+	"unicode/utf8"
+
+	"go.uber.org/zap/buffer"
+)
+
+const _hex = "0123456789abcdef"
+
+// appendJSONString appends s as quoted JSON string.
+// The escaping follows the zap JSON encoder, invalid UTF-8 is replaced by �.
+func appendJSONString(buf *buffer.Buffer, s string) {
+	buf.AppendByte('"')
+	appendJSONEscapedString(buf, s)
+	buf.AppendByte('"')
+}
+
+// appendJSONBytes is the no-alloc equivalent of appendJSONString(buf, string(s)).
+func appendJSONBytes(buf *buffer.Buffer, s []byte) {
+	buf.AppendByte('"')
+	appendJSONEscapedBytes(buf, s)
+	buf.AppendByte('"')
+}
+
+// appendJSONEscapedString appends s escaped for the use within a JSON string without the quotes.
+func appendJSONEscapedString(buf *buffer.Buffer, s string) {
+	for i := 0; i < len(s); {
+		if appendJSONRuneSelf(buf, s[i]) {
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf.AppendString(`�`)
+			i++
+			continue
+		}
+		buf.AppendString(s[i : i+size])
+		i += size
+	}
+}
+
+// appendJSONEscapedBytes is the no-alloc equivalent of appendJSONEscapedString(buf, string(s)).
+func appendJSONEscapedBytes(buf *buffer.Buffer, s []byte) {
+	for i := 0; i < len(s); {
+		if appendJSONRuneSelf(buf, s[i]) {
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf.AppendString(`�`)
+			i++
+			continue
+		}
+		_, _ = buf.Write(s[i : i+size])
+		i += size
+	}
+}
+
+// appendJSONRuneSelf appends b escaped if it is a valid UTF-8 character represented in a single byte.
+func appendJSONRuneSelf(buf *buffer.Buffer, b byte) bool {
+	if b >= utf8.RuneSelf {
+		return false
+	}
+	if 0x20 <= b && b != '\\' && b != '"' {
+		buf.AppendByte(b)
+		return true
+	}
+	switch b {
+	case '\\', '"':
+		buf.AppendByte('\\')
+		buf.AppendByte(b)
+	case '\n':
+		buf.AppendString(`\n`)
+	case '\r':
+		buf.AppendString(`\r`)
+	case '\t':
+		buf.AppendString(`\t`)
+	default:
+		buf.AppendString(`\u00`)
+		buf.AppendByte(_hex[b>>4])
+		buf.AppendByte(_hex[b&0xF])
+	}
+	return true
+}
@@ -0,0 +1,134 @@
+package zapappender
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/delixfe/zapappender/internal/bufferpool"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// JSONWrappingConfig configures the JSON wrapping envelope.
+// Empty keys omit the respective field.
+type JSONWrappingConfig struct {
+	TimeKey    string
+	LevelKey   string
+	LoggerKey  string
+	CallerKey  string
+	StackKey   string
+	PayloadKey string
+	// TimeLayout formats the time. Empty formats the seconds since the epoch as a number.
+	TimeLayout string
+	// Fields are added to every object. Their keys must differ from the keys above.
+	Fields map[string]string
+}
+
+// DefaultJSONWrappingConfig returns a config with the keys used by zap's production encoder config.
+func DefaultJSONWrappingConfig() JSONWrappingConfig {
+	return JSONWrappingConfig{
+		TimeKey:    "ts",
+		LevelKey:   "level",
+		LoggerKey:  "logger",
+		CallerKey:  "caller",
+		StackKey:   "stacktrace",
+		PayloadKey: "msg",
+		TimeLayout: time.RFC3339Nano,
+	}
+}
+
+// NewJSONWrappingEnvelopingFn creates an EnvelopingFn wrapping the encoded message as string into a JSON object
+// with the metadata of the entry, e.g. to send console encoded messages to a sink requiring JSON:
+//
+//	{"ts":"2020-09-13T12:26:40Z","level":"info","logger":"http","msg":"<encoded message>"}
+//
+// The encoded message is stored without its line ending, the object is terminated with a newline
+// if the encoded message was. The static fields follow the entry fields, sorted by key.
+// Keys used more than once are rejected as they would result in duplicate JSON keys.
+func NewJSONWrappingEnvelopingFn(config JSONWrappingConfig) (EnvelopingFn, error) {
+	if config.PayloadKey == "" {
+		return nil, errors.New("payload key is required")
+	}
+	entryKeys := make(map[string]bool)
+	for _, key := range []string{config.TimeKey, config.LevelKey, config.LoggerKey, config.CallerKey, config.StackKey, config.PayloadKey} {
+		if key == "" {
+			continue
+		}
+		if entryKeys[key] {
+			return nil, fmt.Errorf("duplicate key %q", key)
+		}
+		entryKeys[key] = true
+	}
+	keys := make([]string, 0, len(config.Fields))
+	for key := range config.Fields {
+		if entryKeys[key] {
+			return nil, fmt.Errorf("field key %q is used for the entry", key)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	static := bufferpool.Get()
+	for _, key := range keys {
+		static.AppendByte(',')
+		appendJSONString(static, key)
+		static.AppendByte(':')
+		appendJSONString(static, config.Fields[key])
+	}
+	fields := static.String()
+	static.Free()
+
+	return func(p []byte, ent zapcore.Entry, output *buffer.Buffer) error {
+		output.AppendByte('{')
+		first := true
+		appendKey := func(key string) {
+			if !first {
+				output.AppendByte(',')
+			}
+			first = false
+			appendJSONString(output, key)
+			output.AppendByte(':')
+		}
+		if config.TimeKey != "" {
+			appendKey(config.TimeKey)
+			if config.TimeLayout == "" {
+				appendUnixFraction(output, ent.Time, 9)
+			} else {
+				output.AppendByte('"')
+				output.AppendTime(ent.Time, config.TimeLayout)
+				output.AppendByte('"')
+			}
+		}
+		if config.LevelKey != "" {
+			appendKey(config.LevelKey)
+			appendJSONString(output, ent.Level.String())
+		}
+		if config.LoggerKey != "" && ent.LoggerName != "" {
+			appendKey(config.LoggerKey)
+			appendJSONString(output, ent.LoggerName)
+		}
+		if config.CallerKey != "" && ent.Caller.Defined {
+			appendKey(config.CallerKey)
+			output.AppendByte('"')
+			caller := bufferpool.Get()
+			appendTrimmedCaller(caller, ent.Caller)
+			appendJSONEscapedBytes(output, caller.Bytes())
+			caller.Free()
+			output.AppendByte('"')
+		}
+		payload := trimNewline(p)
+		appendKey(config.PayloadKey)
+		appendJSONBytes(output, payload)
+		if config.StackKey != "" && ent.Stack != "" {
+			appendKey(config.StackKey)
+			appendJSONString(output, ent.Stack)
+		}
+		output.AppendString(fields)
+		output.AppendByte('}')
+		if len(payload) < len(p) {
+			output.AppendByte('\n')
+		}
+		return nil
+	}, nil
+}
@@ -0,0 +1,95 @@
+package zapappender_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/delixfe/zapappender"
+	"github.com/delixfe/zapappender/internal"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestJSONWrappingEnvelopingFn(t *testing.T) {
+	config := zapappender.DefaultJSONWrappingConfig()
+	config.Fields = map[string]string{"app": "shop"}
+	envFn, err := zapappender.NewJSONWrappingEnvelopingFn(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &internal.Buffer{}
+	a := zapappender.NewEnveloping(zapappender.NewWriter(out), envFn)
+
+	enc := zapcore.NewConsoleEncoder(zapcore.EncoderConfig{MessageKey: "M", LevelKey: "L", EncodeLevel: zapcore.CapitalLevelEncoder})
+	ent := zapcore.Entry{
+		Level:      zapcore.ErrorLevel,
+		Time:       time.Date(2020, 9, 13, 12, 26, 40, 0, time.UTC),
+		LoggerName: "http",
+		Message:    `failed "badly"`,
+		Caller:     zapcore.NewEntryCaller(0, "/src/app/http/handler.go", 42, true),
+		Stack:      "goroutine 1\n\tmain.go:1",
+	}
+	buf, _ := enc.EncodeEntry(ent, nil)
+	if _, err := a.Write(buf.Bytes(), ent); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(out.String(), "}\n") {
+		t.Errorf("expected a newline terminated object, got %q", out.String())
+	}
+
+	var doc map[string]string
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out.String(), err)
+	}
+	expected := map[string]string{
+		"ts":         "2020-09-13T12:26:40Z",
+		"level":      "error",
+		"logger":     "http",
+		"caller":     "http/handler.go:42",
+		"msg":        "ERROR\tfailed \"badly\"",
+		"stacktrace": "goroutine 1\n\tmain.go:1",
+		"app":        "shop",
+	}
+	if len(doc) != len(expected) {
+		t.Errorf("expected %d fields, got %v", len(expected), doc)
+	}
+	for key, value := range expected {
+		if doc[key] != value {
+			t.Errorf("%s: expected %q, got %q", key, value, doc[key])
+		}
+	}
+}
+
+func TestJSONWrappingEnvelopingFn_omittedKeys(t *testing.T) {
+	envFn, err := zapappender.NewJSONWrappingEnvelopingFn(zapappender.JSONWrappingConfig{PayloadKey: "line", TimeKey: "t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &internal.Buffer{}
+	a := zapappender.NewEnveloping(zapappender.NewWriter(out), envFn)
+	_, _ = a.Write([]byte("hello"), zapcore.Entry{Time: time.Unix(1600000000, 500000000), LoggerName: "http"})
+	if out.String() != `{"t":1600000000.500000000,"line":"hello"}` {
+		t.Errorf("unexpected output %q", out.String())
+	}
+
+	if _, err := zapappender.NewJSONWrappingEnvelopingFn(zapappender.JSONWrappingConfig{}); err == nil {
+		t.Error("expected an error without payload key")
+	}
+}
+
+func TestNewJSONWrappingEnvelopingFn_duplicateKeys_returnsErr(t *testing.T) {
+	withFields := zapappender.DefaultJSONWrappingConfig()
+	withFields.Fields = map[string]string{"service": "shop", "level": "always info"}
+	duplicateEntryKey := zapappender.DefaultJSONWrappingConfig()
+	duplicateEntryKey.LoggerKey = "msg"
+
+	for name, config := range map[string]zapappender.JSONWrappingConfig{
+		"field key used for the entry": withFields,
+		"duplicate entry key":          duplicateEntryKey,
+	} {
+		if _, err := zapappender.NewJSONWrappingEnvelopingFn(config); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package zapappender
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+var _ SynchronizationAwareAppender = &LevelFilter{}
+
+// LevelFilter forwards only messages whose level is enabled by its zapcore.LevelEnabler.
+// Filtered messages are silently dropped.
+//
+// Passing a zap.AtomicLevel allows to adjust the threshold at runtime.
+// Note that the LevelEnabler of the AppenderCore must enable all levels any branch is interested in.
+type LevelFilter struct {
+	zapcore.LevelEnabler
+	primary Appender
+}
+
+func NewLevelFilter(inner Appender, enab zapcore.LevelEnabler) *LevelFilter {
+	return &LevelFilter{
+		LevelEnabler: enab,
+		primary:      inner,
+	}
+}
+
+func (a *LevelFilter) Write(p []byte, ent zapcore.Entry) (n int, err error) {
+	if !a.Enabled(ent.Level) {
+		return len(p), nil
+	}
+	return a.primary.Write(p, ent)
+}
+
+func (a *LevelFilter) WriteFields(p []byte, ent zapcore.Entry, fields Fields) (n int, err error) {
+	if !a.Enabled(ent.Level) {
+		return len(p), nil
+	}
+	return writeWithFields(a.primary, p, ent, fields)
+}
+
+func (a *LevelFilter) WantsFields() bool {
+	return WantsFields(a.primary)
+}
+
+func (a *LevelFilter) Sync() error {
+	return a.primary.Sync()
+}
+
+func (a *LevelFilter) Synchronized() bool {
+	return Synchronized(a.primary)
+}
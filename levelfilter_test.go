@@ -0,0 +1,32 @@
+package zapappender_test
+
+import (
+	"testing"
+
+	"github.com/delixfe/zapappender"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLevelFilter(t *testing.T) {
+	local, localWritten := NewWriteCountingAppender()
+	remote, remoteWritten := NewWriteCountingAppender()
+
+	remoteLevel := zap.NewAtomicLevelAt(zapcore.WarnLevel)
+	tee, _ := zapappender.NewRouter([]zapappender.Route{
+		{Match: zapappender.RouteLevelRange(zapcore.DebugLevel, zapcore.FatalLevel), Appender: local, Continue: true},
+	}, zapappender.NewLevelFilter(remote, remoteLevel))
+
+	core := zapappender.NewAppenderCore(zapcore.NewJSONEncoder(encoderConfig), tee, zapcore.DebugLevel)
+	logger := zap.New(core)
+
+	logger.Debug("debug")
+	logger.Warn("warn")
+	AssertWrittenEquals(t, 2, localWritten, "local")
+	AssertWrittenEquals(t, 1, remoteWritten, "remote")
+
+	remoteLevel.SetLevel(zapcore.DebugLevel)
+	logger.Debug("debug")
+	AssertWrittenEquals(t, 3, localWritten, "local after level change")
+	AssertWrittenEquals(t, 2, remoteWritten, "remote after level change")
+}
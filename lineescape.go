@@ -0,0 +1,153 @@
+package zapappender
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// LineEscapingConfig configures the line escaping envelope.
+type LineEscapingConfig struct {
+	// Replacement replaces control characters instead of escaping them. The result cannot be decoded.
+	Replacement string
+	// KeepTabs leaves tabs unchanged.
+	KeepTabs bool
+}
+
+// NewLineEscapingEnvelopingFn creates an EnvelopingFn keeping each message on a single line.
+//
+// Line breaks and other control characters within the message are escaped as \n, \r, \t or \xHH,
+// backslashes as \\. The final line ending is kept. NewLineUnescapingReader restores the original messages.
+//
+// If config.Replacement is set, the control characters are replaced instead and backslashes are left unchanged.
+// As escaping changes backslashes, this envelope is meant for text encodings rather than JSON, whose
+// encoder already escapes control characters.
+func NewLineEscapingEnvelopingFn(config LineEscapingConfig) EnvelopingFn {
+	replacements := make(map[byte]string)
+	for c := 0; c < 0x20; c++ {
+		if config.Replacement != "" {
+			replacements[byte(c)] = config.Replacement
+		} else {
+			replacements[byte(c)] = `\x` + string([]byte{_hex[c>>4], _hex[c&0xF]})
+		}
+	}
+	if config.Replacement != "" {
+		replacements[0x7f] = config.Replacement
+	} else {
+		replacements[0x7f] = `\x7f`
+		replacements['\\'] = `\\`
+		replacements['\n'] = `\n`
+		replacements['\r'] = `\r`
+		replacements['\t'] = `\t`
+	}
+	if config.KeepTabs {
+		delete(replacements, '\t')
+	}
+	escaper := newByteEscaper(replacements)
+
+	return func(p []byte, ent zapcore.Entry, output *buffer.Buffer) error {
+		payload := trimNewline(p)
+		escaper.appendBytes(output, payload)
+		_, _ = output.Write(p[len(payload):])
+		return nil
+	}
+}
+
+// NewLineEscaping creates an Enveloping keeping each message on a single line as described by NewLineEscapingEnvelopingFn.
+func NewLineEscaping(inner Appender, config LineEscapingConfig) *Enveloping {
+	return NewEnveloping(inner, NewLineEscapingEnvelopingFn(config))
+}
+
+// UnescapeLine appends line with the escape sequences of NewLineEscapingEnvelopingFn decoded to dst.
+func UnescapeLine(dst, line []byte) ([]byte, error) {
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if c != '\\' {
+			dst = append(dst, c)
+			continue
+		}
+		if i+1 >= len(line) {
+			return dst, errors.New("incomplete escape sequence")
+		}
+		i++
+		switch line[i] {
+		case '\\':
+			dst = append(dst, '\\')
+		case 'n':
+			dst = append(dst, '\n')
+		case 'r':
+			dst = append(dst, '\r')
+		case 't':
+			dst = append(dst, '\t')
+		case 'x':
+			if i+2 >= len(line) || fromHex(line[i+1]) < 0 || fromHex(line[i+2]) < 0 {
+				return dst, errors.New("invalid hex escape sequence")
+			}
+			dst = append(dst, byte(fromHex(line[i+1])<<4|fromHex(line[i+2])))
+			i += 2
+		default:
+			return dst, fmt.Errorf("invalid escape sequence \\%c", line[i])
+		}
+	}
+	return dst, nil
+}
+
+func fromHex(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0')
+	case c >= 'a' && c <= 'f':
+		return int(c-'a') + 10
+	case c >= 'A' && c <= 'F':
+		return int(c-'A') + 10
+	}
+	return -1
+}
+
+// NewLineUnescapingReader returns a reader decoding each line of r with UnescapeLine.
+// Line endings are passed through. Reading fails on the first line with an invalid escape sequence.
+func NewLineUnescapingReader(r io.Reader) io.Reader {
+	return &lineUnescapingReader{reader: bufio.NewReader(r)}
+}
+
+type lineUnescapingReader struct {
+	reader  *bufio.Reader
+	buf     []byte
+	pending []byte
+	line    int
+	err     error
+}
+
+func (u *lineUnescapingReader) Read(p []byte) (n int, err error) {
+	for len(u.pending) == 0 {
+		if u.err != nil {
+			return 0, u.err
+		}
+		u.pending, u.err = u.next()
+	}
+	n = copy(p, u.pending)
+	u.pending = u.pending[n:]
+	return n, nil
+}
+
+func (u *lineUnescapingReader) next() ([]byte, error) {
+	line, err := u.reader.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, err
+	}
+	u.line++
+	content := trimNewline(line)
+	decoded, decodeErr := UnescapeLine(u.buf[:0], content)
+	if decodeErr != nil {
+		return nil, fmt.Errorf("line %d: %w", u.line, decodeErr)
+	}
+	u.buf = append(decoded, line[len(content):]...)
+	return u.buf, err
+}
@@ -0,0 +1,58 @@
+package zapappender_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/delixfe/zapappender"
+	"github.com/delixfe/zapappender/internal"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLineEscaping_roundTrip(t *testing.T) {
+	out := &internal.Buffer{}
+	a := zapappender.NewLineEscaping(zapappender.NewWriter(out), zapappender.LineEscapingConfig{})
+	messages := []string{
+		"panic: failed\ngoroutine 1:\n\tmain.go:1\n",
+		"path C:\\tmp\x1b[0m\r\n",
+		"no line ending",
+	}
+	for _, msg := range messages {
+		if _, err := a.Write([]byte(msg), zapcore.Entry{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := `panic: failed\ngoroutine 1:\n\tmain.go:1` + "\n" +
+		`path C:\\tmp\x1b[0m` + "\r\n" +
+		"no line ending"
+	if out.String() != expected {
+		t.Errorf("expected\n%q\ngot\n%q", expected, out.String())
+	}
+
+	decoded, err := io.ReadAll(zapappender.NewLineUnescapingReader(strings.NewReader(out.String())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(decoded) != strings.Join(messages, "") {
+		t.Errorf("expected %q, got %q", strings.Join(messages, ""), decoded)
+	}
+}
+
+func TestLineEscaping_replacement(t *testing.T) {
+	out := &internal.Buffer{}
+	a := zapappender.NewLineEscaping(zapappender.NewWriter(out), zapappender.LineEscapingConfig{Replacement: " | ", KeepTabs: true})
+	_, _ = a.Write([]byte("a\nb\tc\\d\n"), zapcore.Entry{})
+	if out.String() != "a | b\tc\\d\n" {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
+
+func TestUnescapeLine_invalid(t *testing.T) {
+	for _, line := range []string{`trailing\`, `bad\q`, `hex\x1`, `hex\xzz`} {
+		if _, err := zapappender.UnescapeLine(nil, []byte(line)); err == nil {
+			t.Errorf("expected an error for %q", line)
+		}
+	}
+}
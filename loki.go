@@ -0,0 +1,288 @@
+package zapappender
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/delixfe/zapappender/internal/bufferpool"
+	"github.com/delixfe/zapappender/internal/protowire"
+	"github.com/delixfe/zapappender/internal/snappy"
+	"go.uber.org/multierr"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// ErrLokiOutOfOrder is returned if Loki rejected entries as out of order or too old.
+var ErrLokiOutOfOrder = errors.New("loki rejected out of order entries")
+
+// lokiLabelEscaper escapes label values like the Prometheus text format and LogQL.
+var lokiLabelEscaper = newByteEscaper(map[byte]string{'\\': `\\`, '"': `\"`, '\n': `\n`})
+
+// LokiConfig configures the Loki appender.
+type LokiConfig struct {
+	// Labels are added to every stream.
+	Labels map[string]string
+	// LevelLabel is the name of the label holding the level, e.g. "level". Empty omits the label.
+	LevelLabel string
+	// LoggerLabel is the name of the label holding the logger name, e.g. "logger". Empty omits the label.
+	LoggerLabel string
+	// FieldLabels are the names of the fields whose values become labels of the same name.
+	// Entries without the field get no label. Keep the cardinality of the fields low.
+	FieldLabels []string
+	// Protobuf sends snappy compressed protobuf instead of JSON.
+	Protobuf bool
+}
+
+var (
+	_ SynchronizationAwareAppender = &Loki{}
+	_ FieldsAwareAppender          = &Loki{}
+)
+
+// Loki pushes messages to the Grafana Loki push API.
+//
+// The stream labels are derived from the static labels, the level, the logger name and the FieldLabels.
+// Messages are batched per stream. Within a stream, the order of the messages is kept and timestamps
+// going backwards are raised to the last sent timestamp, so Loki does not reject them.
+//
+// Batches rejected as out of order are neither retried nor forwarded, as Loki accepted
+// the other entries of the batch; ErrLokiOutOfOrder is returned instead.
+type Loki struct {
+	// readonly
+	url       string
+	config    LokiConfig
+	transport *httpTransport
+	batch     *batcher
+
+	// state
+	streams  sync.Map         // lokiStreamKey -> *lokiStream
+	byKey    sync.Map         // lokiStream.key -> *lokiStream
+	lastSent map[string]int64 // guarded by the serialized flushes
+}
+
+type lokiStreamKey struct {
+	level  zapcore.Level
+	logger string
+	fields string // values of the field labels, separated by \xff
+}
+
+type lokiStream struct {
+	labels [][2]string // sorted by name
+	key    string      // labels in the Prometheus text format
+}
+
+// NewLoki creates a Loki appender pushing to url, e.g. http://localhost:3100/loki/api/v1/push.
+func NewLoki(url string, config LokiConfig, options ...HTTPOption) (*Loki, error) {
+	if url == "" {
+		return nil, errors.New("url is required")
+	}
+	names := make(map[string]bool, len(config.Labels)+len(config.FieldLabels))
+	for name := range config.Labels {
+		names[name] = true
+	}
+	for _, name := range config.FieldLabels {
+		if names[name] {
+			return nil, fmt.Errorf("label %q is defined twice", name)
+		}
+		names[name] = true
+	}
+	for _, name := range []string{config.LevelLabel, config.LoggerLabel} {
+		if name != "" && names[name] {
+			return nil, fmt.Errorf("label %q is defined twice", name)
+		}
+	}
+	if config.LevelLabel != "" && config.LevelLabel == config.LoggerLabel {
+		return nil, errors.New("level and logger label must differ")
+	}
+	transport, err := newHTTPTransport(options)
+	if err != nil {
+		return nil, err
+	}
+	a := &Loki{
+		url:       url,
+		config:    config,
+		transport: transport,
+		lastSent:  make(map[string]int64),
+	}
+	a.batch = transport.newBatcher(a.send)
+	return a, nil
+}
+
+func (a *Loki) Write(p []byte, ent zapcore.Entry) (n int, err error) {
+	return a.WriteFields(p, ent, Fields{})
+}
+
+func (a *Loki) WriteFields(p []byte, ent zapcore.Entry, fields Fields) (n int, err error) {
+	stream := a.stream(ent, fields)
+	buf := bufferpool.Get()
+	_, _ = buf.Write(p)
+	if err = a.batch.add(stream.key, buf, ent); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// WantsFields returns true if FieldLabels are configured.
+func (a *Loki) WantsFields() bool {
+	return len(a.config.FieldLabels) > 0
+}
+
+func (a *Loki) stream(ent zapcore.Entry, fields Fields) *lokiStream {
+	key := lokiStreamKey{}
+	if a.config.LevelLabel != "" {
+		key.level = ent.Level
+	}
+	if a.config.LoggerLabel != "" {
+		key.logger = ent.LoggerName
+	}
+	var values []string
+	if len(a.config.FieldLabels) > 0 && fields.Len() > 0 {
+		values = make([]string, len(a.config.FieldLabels))
+		for i, name := range a.config.FieldLabels {
+			values[i] = fields.String(name)
+		}
+		key.fields = strings.Join(values, "\xff")
+	}
+	if stream, ok := a.streams.Load(key); ok {
+		return stream.(*lokiStream)
+	}
+
+	labels := make([][2]string, 0, len(a.config.Labels)+len(a.config.FieldLabels)+2)
+	for name, value := range a.config.Labels {
+		labels = append(labels, [2]string{name, value})
+	}
+	if a.config.LevelLabel != "" {
+		labels = append(labels, [2]string{a.config.LevelLabel, ent.Level.String()})
+	}
+	if a.config.LoggerLabel != "" && ent.LoggerName != "" {
+		labels = append(labels, [2]string{a.config.LoggerLabel, ent.LoggerName})
+	}
+	for i, value := range values {
+		if value != "" {
+			labels = append(labels, [2]string{a.config.FieldLabels[i], value})
+		}
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i][0] < labels[j][0] })
+
+	buf := bufferpool.Get()
+	defer buf.Free()
+	buf.AppendByte('{')
+	for i, label := range labels {
+		if i > 0 {
+			buf.AppendString(", ")
+		}
+		buf.AppendString(label[0])
+		buf.AppendString(`="`)
+		lokiLabelEscaper.appendString(buf, label[1])
+		buf.AppendByte('"')
+	}
+	buf.AppendByte('}')
+
+	stream, _ := a.streams.LoadOrStore(key, &lokiStream{labels: labels, key: buf.String()})
+	byKey, _ := a.byKey.LoadOrStore(stream.(*lokiStream).key, stream)
+	return byKey.(*lokiStream)
+}
+
+// timestamps returns the timestamps of the items in nanoseconds, never going backwards within the stream.
+// must only be called by send
+func (a *Loki) timestamps(key string, items []batchItem) []int64 {
+	last := a.lastSent[key]
+	timestamps := make([]int64, len(items))
+	for i, item := range items {
+		ts := item.ent.Time.UnixNano()
+		if ts < last {
+			ts = last
+		}
+		timestamps[i] = ts
+		last = ts
+	}
+	a.lastSent[key] = last
+	return timestamps
+}
+
+// send is called by the batcher, the calls are serialized.
+func (a *Loki) send(key string, items []batchItem) error {
+	timestamps := a.timestamps(key, items)
+	value, _ := a.byKey.Load(key)
+	stream := value.(*lokiStream)
+
+	var (
+		body        []byte
+		contentType string
+	)
+	if a.config.Protobuf {
+		contentType = "application/x-protobuf"
+		body = snappy.Encode(nil, a.encodeProtobuf(stream, items, timestamps))
+	} else {
+		contentType = "application/json"
+		buf := bufferpool.Get()
+		defer buf.Free()
+		a.encodeJSON(buf, stream, items, timestamps)
+		body = buf.Bytes()
+	}
+
+	_, err := a.transport.post(a.url, contentType, body, nil)
+	if err == nil {
+		return nil
+	}
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == 400 &&
+		(strings.Contains(statusErr.Body, "out of order") || strings.Contains(statusErr.Body, "too far behind")) {
+		return fmt.Errorf("%w: %s", ErrLokiOutOfOrder, statusErr.Body)
+	}
+	return multierr.Append(err, a.transport.forwardFailed(items))
+}
+
+func (a *Loki) encodeJSON(buf *buffer.Buffer, stream *lokiStream, items []batchItem, timestamps []int64) {
+	buf.AppendString(`{"streams":[{"stream":{`)
+	for i, label := range stream.labels {
+		if i > 0 {
+			buf.AppendByte(',')
+		}
+		appendJSONString(buf, label[0])
+		buf.AppendByte(':')
+		appendJSONString(buf, label[1])
+	}
+	buf.AppendString(`},"values":[`)
+	for i, item := range items {
+		if i > 0 {
+			buf.AppendByte(',')
+		}
+		buf.AppendString(`["`)
+		buf.AppendInt(timestamps[i])
+		buf.AppendString(`",`)
+		appendJSONBytes(buf, trimNewline(item.buf.Bytes()))
+		buf.AppendByte(']')
+	}
+	buf.AppendString(`]}]}`)
+}
+
+// encodeProtobuf encodes a logproto.PushRequest.
+func (a *Loki) encodeProtobuf(stream *lokiStream, items []batchItem, timestamps []int64) []byte {
+	var streamMsg, entry, timestamp []byte
+	streamMsg = protowire.AppendStringField(streamMsg, 1, stream.key)
+	for i, item := range items {
+		timestamp = protowire.AppendVarintField(timestamp[:0], 1, uint64(timestamps[i]/1e9))
+		timestamp = protowire.AppendVarintField(timestamp, 2, uint64(timestamps[i]%1e9))
+		entry = protowire.AppendBytesField(entry[:0], 1, timestamp)
+		entry = protowire.AppendBytesField(entry, 2, trimNewline(item.buf.Bytes()))
+		streamMsg = protowire.AppendBytesField(streamMsg, 2, entry)
+	}
+	return protowire.AppendBytesField(nil, 1, streamMsg)
+}
+
+// Sync sends the pending batches.
+func (a *Loki) Sync() error {
+	return a.batch.flush()
+}
+
+func (a *Loki) Synchronized() bool {
+	return true
+}
+
+// Shutdown stops the periodic flushing and sends the pending batches.
+func (a *Loki) Shutdown() error {
+	return a.batch.stop()
+}
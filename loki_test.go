@@ -0,0 +1,185 @@
+package zapappender_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/delixfe/zapappender"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type lokiPush struct {
+	Streams []struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	} `json:"streams"`
+}
+
+func TestLoki(t *testing.T) {
+	pushes := make(chan lokiPush, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var push lokiPush
+		if err := json.NewDecoder(r.Body).Decode(&push); err != nil {
+			t.Error(err)
+		}
+		pushes <- push
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	a, err := zapappender.NewLoki(server.URL, zapappender.LokiConfig{
+		Labels:      map[string]string{"app": "shop"},
+		LevelLabel:  "level",
+		LoggerLabel: "logger",
+	}, zapappender.HTTPFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Shutdown()
+
+	ts := time.Unix(1600000000, 0)
+	writes := []struct {
+		level zapcore.Level
+		time  time.Time
+		msg   string
+	}{
+		{zapcore.InfoLevel, ts, "first"},
+		{zapcore.InfoLevel, ts.Add(-time.Second), "backwards"},
+		{zapcore.InfoLevel, ts.Add(time.Second), "later"},
+	}
+	for _, w := range writes {
+		if _, err := a.Write([]byte(w.msg+"\n"), zapcore.Entry{Level: w.level, Time: w.time, LoggerName: "http"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	push := <-pushes
+	if len(push.Streams) != 1 {
+		t.Fatalf("expected one stream, got %v", push)
+	}
+	stream := push.Streams[0]
+	expectedLabels := map[string]string{"app": "shop", "level": "info", "logger": "http"}
+	for name, value := range expectedLabels {
+		if stream.Stream[name] != value {
+			t.Errorf("label %s: expected %q, got %q", name, value, stream.Stream[name])
+		}
+	}
+	expectedValues := [][2]string{
+		{"1600000000000000000", "first"},
+		{"1600000000000000000", "backwards"},
+		{"1600000001000000000", "later"},
+	}
+	if len(stream.Values) != len(expectedValues) {
+		t.Fatalf("expected %d values, got %v", len(expectedValues), stream.Values)
+	}
+	for i := range expectedValues {
+		if stream.Values[i] != expectedValues[i] {
+			t.Errorf("value %d: expected %v, got %v", i, expectedValues[i], stream.Values[i])
+		}
+	}
+
+	// another level is another stream
+	_, _ = a.Write([]byte("failed\n"), zapcore.Entry{Level: zapcore.ErrorLevel, Time: ts, LoggerName: "http"})
+	if err := a.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if push := <-pushes; push.Streams[0].Stream["level"] != "error" {
+		t.Errorf("expected an error stream, got %v", push)
+	}
+}
+
+func TestLoki_protobuf(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/x-protobuf" {
+			t.Errorf("unexpected content type %q", r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		labels := `{app="shop", team="a\\b \"c\"` + "\t" + `\n"}`
+		if !bytes.Contains(body, []byte(labels)) || !bytes.Contains(body, []byte("hello")) {
+			t.Errorf("unexpected body %q", body)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	a, err := zapappender.NewLoki(server.URL, zapappender.LokiConfig{
+		Labels:   map[string]string{"app": "shop", "team": "a\\b \"c\"\t\n"},
+		Protobuf: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Shutdown()
+	_, _ = a.Write([]byte("hello\n"), zapcore.Entry{Time: time.Now()})
+	if err := a.Sync(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoki_outOfOrder_returnsErr(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "entry with timestamp 2020-09-13 is out of order", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	a, err := zapappender.NewLoki(server.URL, zapappender.LokiConfig{}, zapappender.HTTPOnFailureForwardTo(NewTestFailOnWriteAppender(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Shutdown()
+	_, _ = a.Write([]byte("hello\n"), zapcore.Entry{Time: time.Now()})
+	if err := a.Sync(); !errors.Is(err, zapappender.ErrLokiOutOfOrder) {
+		t.Errorf("expected ErrLokiOutOfOrder, got %v", err)
+	}
+}
+
+func TestNewLoki_duplicateLabel_returnsErr(t *testing.T) {
+	_, err := zapappender.NewLoki("http://localhost", zapappender.LokiConfig{
+		Labels:     map[string]string{"level": "x"},
+		LevelLabel: "level",
+	})
+	if err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestLoki_fieldLabels(t *testing.T) {
+	pushes := make(chan lokiPush, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var push lokiPush
+		if err := json.NewDecoder(r.Body).Decode(&push); err != nil {
+			t.Error(err)
+		}
+		pushes <- push
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	a, err := zapappender.NewLoki(server.URL, zapappender.LokiConfig{
+		FieldLabels: []string{"tenant"},
+	}, zapappender.HTTPFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Shutdown()
+
+	logger := zap.New(zapappender.NewAppenderCore(zapcore.NewJSONEncoder(encoderConfig), a, zapcore.DebugLevel))
+	logger.With(zap.String("tenant", "acme")).Info("hello")
+	if err := a.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	push := <-pushes
+	if len(push.Streams) != 1 || push.Streams[0].Stream["tenant"] != "acme" {
+		t.Errorf("expected a stream labeled with the tenant, got %+v", push)
+	}
+}
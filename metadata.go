@@ -0,0 +1,243 @@
+package zapappender
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// Metadata describes the host and process emitting the logs.
+// Empty fields could not be discovered.
+type Metadata struct {
+	Hostname     string
+	PID          int
+	AppName      string
+	AppVersion   string
+	ContainerID  string
+	PodName      string
+	PodNamespace string
+	NodeName     string
+}
+
+type metadataConfig struct {
+	appName    string
+	appVersion string
+	cgroupFile string
+	mountsFile string
+}
+
+type MetadataOption interface {
+	apply(*metadataConfig) error
+}
+
+type metadataOptionsFunc func(*metadataConfig) error
+
+func (f metadataOptionsFunc) apply(c *metadataConfig) error {
+	return f(c)
+}
+
+// MetadataAppName sets the application name instead of discovering it.
+func MetadataAppName(name string) MetadataOption {
+	return metadataOptionsFunc(func(c *metadataConfig) error {
+		c.appName = name
+		return nil
+	})
+}
+
+// MetadataAppVersion sets the application version instead of discovering it.
+func MetadataAppVersion(version string) MetadataOption {
+	return metadataOptionsFunc(func(c *metadataConfig) error {
+		c.appVersion = version
+		return nil
+	})
+}
+
+// MetadataCgroupFile sets the files the container id is read from.
+// The defaults are /proc/self/cgroup and /proc/self/mountinfo.
+func MetadataCgroupFile(cgroupFile, mountsFile string) MetadataOption {
+	return metadataOptionsFunc(func(c *metadataConfig) error {
+		c.cgroupFile = cgroupFile
+		c.mountsFile = mountsFile
+		return nil
+	})
+}
+
+// DiscoverMetadata resolves the metadata of the current process. It is meant to be called once at startup.
+//
+// The values are taken from:
+//
+//	Hostname      os.Hostname
+//	PID           os.Getpid
+//	AppName       APP_NAME, OTEL_SERVICE_NAME or the name of the executable
+//	AppVersion    APP_VERSION or the version of the main module
+//	ContainerID   /proc/self/cgroup or, for cgroup v2, /proc/self/mountinfo
+//	PodName       POD_NAME
+//	PodNamespace  POD_NAMESPACE
+//	NodeName      NODE_NAME
+//
+// The Kubernetes variables are expected to be set with the downward API.
+// An error is only returned for invalid options; values that cannot be discovered are left empty.
+func DiscoverMetadata(options ...MetadataOption) (Metadata, error) {
+	config, err := newMetadataConfig(options)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	m := Metadata{
+		PID:          os.Getpid(),
+		AppName:      config.appName,
+		AppVersion:   config.appVersion,
+		PodName:      os.Getenv("POD_NAME"),
+		PodNamespace: os.Getenv("POD_NAMESPACE"),
+		NodeName:     os.Getenv("NODE_NAME"),
+	}
+	m.Hostname, _ = os.Hostname()
+	if m.AppName == "" {
+		m.AppName = firstEnv("APP_NAME", "OTEL_SERVICE_NAME")
+	}
+	if m.AppName == "" {
+		m.AppName = filepath.Base(os.Args[0])
+	}
+	if m.AppVersion == "" {
+		m.AppVersion = os.Getenv("APP_VERSION")
+	}
+	if m.AppVersion == "" {
+		if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "(devel)" {
+			m.AppVersion = info.Main.Version
+		}
+	}
+	m.ContainerID = containerIDFromFile(config.cgroupFile, false)
+	if m.ContainerID == "" {
+		m.ContainerID = containerIDFromFile(config.mountsFile, true)
+	}
+	return m, nil
+}
+
+func newMetadataConfig(options []MetadataOption) (c *metadataConfig, err error) {
+	c = &metadataConfig{
+		cgroupFile: "/proc/self/cgroup",
+		mountsFile: "/proc/self/mountinfo",
+	}
+	for _, option := range options {
+		err = option.apply(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+var containerIDRegexp = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// containerIDFromFile returns the first container id found in file.
+// In mountinfo, only the mounts of the container's own files like /etc/hostname are considered,
+// as other mounts may reference images or other containers.
+func containerIDFromFile(file string, mounts bool) string {
+	f, err := os.Open(file)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if mounts && !strings.Contains(line, "/containers/") {
+			continue
+		}
+		if id := containerIDRegexp.FindString(line); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// Fields returns the discovered values by short keys: hostname, pid, app, version, container_id, pod,
+// namespace and node. They can be used as GELFConfig.AdditionalFields or JSONWrappingConfig.Fields.
+func (m Metadata) Fields() map[string]string {
+	return m.fields(metadataKeys)
+}
+
+var metadataKeys = [8]string{"hostname", "pid", "app", "version", "container_id", "pod", "namespace", "node"}
+
+// OTelResourceAttributes returns the discovered values keyed by the OpenTelemetry semantic conventions,
+// to be used as OTLPConfig.ResourceAttributes.
+func (m Metadata) OTelResourceAttributes() map[string]string {
+	return m.fields([8]string{"host.name", "process.pid", "service.name", "service.version", "container.id",
+		"k8s.pod.name", "k8s.namespace.name", "k8s.node.name"})
+}
+
+func (m Metadata) fields(keys [8]string) map[string]string {
+	values := m.values()
+	fields := make(map[string]string, len(keys))
+	for i, key := range keys {
+		if values[i] != "" {
+			fields[key] = values[i]
+		}
+	}
+	return fields
+}
+
+// values returns the values in the order of the keys of Fields.
+func (m Metadata) values() [8]string {
+	pid := ""
+	if m.PID != 0 {
+		pid = strconv.Itoa(m.PID)
+	}
+	return [8]string{m.Hostname, pid, m.AppName, m.AppVersion, m.ContainerID, m.PodName, m.PodNamespace, m.NodeName}
+}
+
+// Expand replaces the placeholders ${hostname}, ${pid}, ${app}, ${version}, ${container_id}, ${pod},
+// ${namespace} and ${node} in s. Unknown placeholders are left unchanged.
+func (m Metadata) Expand(s string) string {
+	fields := m.Fields()
+	var sb strings.Builder
+	for {
+		start := strings.Index(s, "${")
+		if start == -1 {
+			break
+		}
+		end := strings.IndexByte(s[start:], '}')
+		if end == -1 {
+			break
+		}
+		end += start
+		name := s[start+2 : end]
+		sb.WriteString(s[:start])
+		if isMetadataKey(name) {
+			sb.WriteString(fields[name])
+		} else {
+			sb.WriteString(s[start : end+1])
+		}
+		s = s[end+1:]
+	}
+	sb.WriteString(s)
+	return sb.String()
+}
+
+func isMetadataKey(name string) bool {
+	for _, key := range metadataKeys {
+		if key == name {
+			return true
+		}
+	}
+	return false
+}
+
+// NewEnvelopingMetadataPrefix creates an Enveloping prepending prefix with the placeholders expanded by m.Expand,
+// e.g. "${hostname} ${app}[${pid}]: ".
+func NewEnvelopingMetadataPrefix(inner Appender, m Metadata, prefix string) *Enveloping {
+	return NewEnvelopingPreSuffix(inner, m.Expand(prefix), "")
+}
@@ -0,0 +1,88 @@
+package zapappender_test
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/delixfe/zapappender"
+	"github.com/delixfe/zapappender/internal"
+	"go.uber.org/zap/zapcore"
+)
+
+const testContainerID = "3f4e8a1b2c3d4e5f60718293a4b5c6d7e8f901122334455667788990aabbccdd"
+
+func TestDiscoverMetadata(t *testing.T) {
+	dir := t.TempDir()
+	cgroup := filepath.Join(dir, "cgroup")
+	content := "12:pids:/kubepods/burstable/pod123/" + testContainerID + "\n0::/\n"
+	if err := os.WriteFile(cgroup, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// isolate from the environment, e.g. when running in a pod
+	for _, name := range []string{"NODE_NAME", "OTEL_SERVICE_NAME", "APP_NAME", "APP_VERSION", "POD_NAME", "POD_NAMESPACE"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("POD_NAME", "shop-7d9f")
+	t.Setenv("POD_NAMESPACE", "prod")
+	t.Setenv("APP_NAME", "shop")
+
+	m, err := zapappender.DiscoverMetadata(
+		zapappender.MetadataAppVersion("1.2.3"),
+		zapappender.MetadataCgroupFile(cgroup, filepath.Join(dir, "missing")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostname, _ := os.Hostname()
+	expected := zapappender.Metadata{
+		Hostname:     hostname,
+		PID:          os.Getpid(),
+		AppName:      "shop",
+		AppVersion:   "1.2.3",
+		ContainerID:  testContainerID,
+		PodName:      "shop-7d9f",
+		PodNamespace: "prod",
+	}
+	if m != expected {
+		t.Errorf("expected %+v, got %+v", expected, m)
+	}
+
+	attributes := m.OTelResourceAttributes()
+	if attributes["service.name"] != "shop" || attributes["container.id"] != testContainerID ||
+		attributes["process.pid"] != strconv.Itoa(os.Getpid()) {
+		t.Errorf("unexpected resource attributes %v", attributes)
+	}
+	if _, ok := attributes["k8s.node.name"]; ok {
+		t.Error("expected empty values to be omitted")
+	}
+}
+
+func TestDiscoverMetadata_cgroupV2(t *testing.T) {
+	dir := t.TempDir()
+	cgroup := filepath.Join(dir, "cgroup")
+	mounts := filepath.Join(dir, "mountinfo")
+	_ = os.WriteFile(cgroup, []byte("0::/\n"), 0o600)
+	_ = os.WriteFile(mounts, []byte(
+		"1 0 0:1 / / rw - overlay overlay rw,lowerdir=/var/lib/docker/overlay2/"+testContainerID[:60]+"ffff/diff\n"+
+			"2 1 8:1 /var/lib/docker/containers/"+testContainerID+"/hostname /etc/hostname rw - ext4 /dev/sda1 rw\n"), 0o600)
+
+	m, err := zapappender.DiscoverMetadata(zapappender.MetadataCgroupFile(cgroup, mounts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ContainerID != testContainerID {
+		t.Errorf("expected container id %s, got %q", testContainerID, m.ContainerID)
+	}
+}
+
+func TestEnvelopingMetadataPrefix(t *testing.T) {
+	m := zapappender.Metadata{Hostname: "web-1", PID: 42, AppName: "shop"}
+	out := &internal.Buffer{}
+	a := zapappender.NewEnvelopingMetadataPrefix(zapappender.NewWriter(out), m, "${hostname} ${app}[${pid}] ${pod}${unknown}: ")
+	_, _ = a.Write([]byte("hello\n"), zapcore.Entry{})
+	if out.String() != "web-1 shop[42] ${unknown}: hello\n" {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
@@ -0,0 +1,269 @@
+package zapappender
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/delixfe/zapappender/internal/bufferpool"
+	"github.com/delixfe/zapappender/internal/protowire"
+	"go.uber.org/multierr"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// OTLPConfig configures the OTLP appender.
+type OTLPConfig struct {
+	// ResourceAttributes describe the entity producing the logs, e.g. service.name.
+	ResourceAttributes map[string]string
+	// ScopeName is the name of the instrumentation scope. The default is the module path of zapappender.
+	ScopeName string
+	// Protobuf sends binary protobuf instead of JSON.
+	Protobuf bool
+}
+
+var _ SynchronizationAwareAppender = &OTLP{}
+
+// OTLP exports messages as OpenTelemetry log records using OTLP/HTTP.
+//
+// The encoded message becomes the body of the log record. Severity and timestamp are taken from the entry,
+// logger name, caller and stack are added as attributes.
+type OTLP struct {
+	url       string
+	config    OTLPConfig
+	resource  [][2]string // sorted by key
+	transport *httpTransport
+	batch     *batcher
+}
+
+// NewOTLP creates an OTLP appender exporting to url, e.g. http://localhost:4318/v1/logs.
+func NewOTLP(url string, config OTLPConfig, options ...HTTPOption) (*OTLP, error) {
+	if url == "" {
+		return nil, errors.New("url is required")
+	}
+	if config.ScopeName == "" {
+		config.ScopeName = "github.com/delixfe/zapappender"
+	}
+	transport, err := newHTTPTransport(options)
+	if err != nil {
+		return nil, err
+	}
+	resource := make([][2]string, 0, len(config.ResourceAttributes))
+	for key, value := range config.ResourceAttributes {
+		resource = append(resource, [2]string{key, value})
+	}
+	sort.Slice(resource, func(i, j int) bool { return resource[i][0] < resource[j][0] })
+
+	a := &OTLP{
+		url:       url,
+		config:    config,
+		resource:  resource,
+		transport: transport,
+	}
+	a.batch = transport.newBatcher(a.send)
+	return a, nil
+}
+
+func (a *OTLP) Write(p []byte, ent zapcore.Entry) (n int, err error) {
+	buf := bufferpool.Get()
+	_, _ = buf.Write(p)
+	if err = a.batch.add("", buf, ent); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// otlpSeverityNumber maps a level to the OpenTelemetry severity number.
+func otlpSeverityNumber(lvl zapcore.Level) int {
+	switch {
+	case lvl <= zapcore.DebugLevel:
+		return 5 // DEBUG
+	case lvl == zapcore.InfoLevel:
+		return 9 // INFO
+	case lvl == zapcore.WarnLevel:
+		return 13 // WARN
+	case lvl == zapcore.ErrorLevel:
+		return 17 // ERROR
+	case lvl == zapcore.DPanicLevel:
+		return 18 // ERROR2
+	case lvl == zapcore.PanicLevel:
+		return 19 // ERROR3
+	default:
+		return 21 // FATAL
+	}
+}
+
+// send is called by the batcher, the calls are serialized.
+func (a *OTLP) send(_ string, items []batchItem) error {
+	var (
+		body        []byte
+		contentType string
+	)
+	if a.config.Protobuf {
+		contentType = "application/x-protobuf"
+		body = a.encodeProtobuf(items)
+	} else {
+		contentType = "application/json"
+		buf := bufferpool.Get()
+		defer buf.Free()
+		a.encodeJSON(buf, items)
+		body = buf.Bytes()
+	}
+
+	respBody, err := a.transport.post(a.url, contentType, body, nil)
+	if err != nil {
+		return multierr.Append(err, a.transport.forwardFailed(items))
+	}
+	if a.config.Protobuf {
+		return nil
+	}
+	var resp struct {
+		PartialSuccess *struct {
+			RejectedLogRecords json.Number `json:"rejectedLogRecords"`
+			ErrorMessage       string      `json:"errorMessage"`
+		} `json:"partialSuccess"`
+	}
+	if json.Unmarshal(respBody, &resp) == nil && resp.PartialSuccess != nil &&
+		resp.PartialSuccess.RejectedLogRecords != "" && resp.PartialSuccess.RejectedLogRecords != "0" {
+		return fmt.Errorf("otlp rejected %s log records: %s",
+			resp.PartialSuccess.RejectedLogRecords, resp.PartialSuccess.ErrorMessage)
+	}
+	return nil
+}
+
+func (a *OTLP) encodeJSON(buf *buffer.Buffer, items []batchItem) {
+	appendAttribute := func(key string, value func()) {
+		buf.AppendString(`{"key":`)
+		appendJSONString(buf, key)
+		buf.AppendString(`,"value":`)
+		value()
+		buf.AppendByte('}')
+	}
+	appendString := func(s string) func() {
+		return func() {
+			buf.AppendString(`{"stringValue":`)
+			appendJSONString(buf, s)
+			buf.AppendByte('}')
+		}
+	}
+
+	buf.AppendString(`{"resourceLogs":[{"resource":{"attributes":[`)
+	for i, attr := range a.resource {
+		if i > 0 {
+			buf.AppendByte(',')
+		}
+		appendAttribute(attr[0], appendString(attr[1]))
+	}
+	buf.AppendString(`]},"scopeLogs":[{"scope":{"name":`)
+	appendJSONString(buf, a.config.ScopeName)
+	buf.AppendString(`},"logRecords":[`)
+	for i, item := range items {
+		ent := item.ent
+		if i > 0 {
+			buf.AppendByte(',')
+		}
+		buf.AppendString(`{"timeUnixNano":"`)
+		buf.AppendInt(ent.Time.UnixNano())
+		buf.AppendString(`","severityNumber":`)
+		buf.AppendInt(int64(otlpSeverityNumber(ent.Level)))
+		buf.AppendString(`,"severityText":`)
+		appendJSONString(buf, ent.Level.CapitalString())
+		buf.AppendString(`,"body":{"stringValue":`)
+		appendJSONBytes(buf, trimNewline(item.buf.Bytes()))
+		buf.AppendString(`},"attributes":[`)
+		separator := func() {}
+		comma := func() { buf.AppendByte(',') }
+		if ent.LoggerName != "" {
+			appendAttribute("logger.name", appendString(ent.LoggerName))
+			separator = comma
+		}
+		if ent.Caller.Defined {
+			separator()
+			appendAttribute("code.filepath", appendString(ent.Caller.File))
+			buf.AppendByte(',')
+			appendAttribute("code.lineno", func() {
+				buf.AppendString(`{"intValue":"`)
+				buf.AppendInt(int64(ent.Caller.Line))
+				buf.AppendString(`"}`)
+			})
+			if ent.Caller.Function != "" {
+				buf.AppendByte(',')
+				appendAttribute("code.function", appendString(ent.Caller.Function))
+			}
+			separator = comma
+		}
+		if ent.Stack != "" {
+			separator()
+			appendAttribute("code.stacktrace", appendString(ent.Stack))
+		}
+		buf.AppendString(`]}`)
+	}
+	buf.AppendString(`]}]}]}`)
+}
+
+// encodeProtobuf encodes an ExportLogsServiceRequest.
+func (a *OTLP) encodeProtobuf(items []batchItem) []byte {
+	var resource, scopeLogs, record, attr []byte
+	for _, kv := range a.resource {
+		attr = otlpAppendStringKeyValue(attr[:0], kv[0], kv[1])
+		resource = protowire.AppendBytesField(resource, 1, attr)
+	}
+
+	scope := protowire.AppendStringField(nil, 1, a.config.ScopeName)
+	scopeLogs = protowire.AppendBytesField(scopeLogs, 1, scope)
+	for _, item := range items {
+		ent := item.ent
+		record = protowire.AppendFixed64Field(record[:0], 1, uint64(ent.Time.UnixNano()))
+		record = protowire.AppendVarintField(record, 2, uint64(otlpSeverityNumber(ent.Level)))
+		record = protowire.AppendStringField(record, 3, ent.Level.CapitalString())
+		body := protowire.AppendBytesField(nil, 1, trimNewline(item.buf.Bytes()))
+		record = protowire.AppendBytesField(record, 5, body)
+		if ent.LoggerName != "" {
+			attr = otlpAppendStringKeyValue(attr[:0], "logger.name", ent.LoggerName)
+			record = protowire.AppendBytesField(record, 6, attr)
+		}
+		if ent.Caller.Defined {
+			attr = otlpAppendStringKeyValue(attr[:0], "code.filepath", ent.Caller.File)
+			record = protowire.AppendBytesField(record, 6, attr)
+			value := protowire.AppendVarintField(nil, 3, uint64(ent.Caller.Line))
+			attr = protowire.AppendStringField(attr[:0], 1, "code.lineno")
+			attr = protowire.AppendBytesField(attr, 2, value)
+			record = protowire.AppendBytesField(record, 6, attr)
+			if ent.Caller.Function != "" {
+				attr = otlpAppendStringKeyValue(attr[:0], "code.function", ent.Caller.Function)
+				record = protowire.AppendBytesField(record, 6, attr)
+			}
+		}
+		if ent.Stack != "" {
+			attr = otlpAppendStringKeyValue(attr[:0], "code.stacktrace", ent.Stack)
+			record = protowire.AppendBytesField(record, 6, attr)
+		}
+		scopeLogs = protowire.AppendBytesField(scopeLogs, 2, record)
+	}
+
+	resourceLogs := protowire.AppendBytesField(nil, 1, resource)
+	resourceLogs = protowire.AppendBytesField(resourceLogs, 2, scopeLogs)
+	return protowire.AppendBytesField(nil, 1, resourceLogs)
+}
+
+// otlpAppendStringKeyValue appends the fields of a KeyValue with a string AnyValue.
+func otlpAppendStringKeyValue(b []byte, key, value string) []byte {
+	b = protowire.AppendStringField(b, 1, key)
+	anyValue := protowire.AppendStringField(nil, 1, value)
+	return protowire.AppendBytesField(b, 2, anyValue)
+}
+
+// Sync sends the pending batch.
+func (a *OTLP) Sync() error {
+	return a.batch.flush()
+}
+
+func (a *OTLP) Synchronized() bool {
+	return true
+}
+
+// Shutdown stops the periodic flushing and sends the pending batch.
+func (a *OTLP) Shutdown() error {
+	return a.batch.stop()
+}
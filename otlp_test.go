@@ -0,0 +1,150 @@
+package zapappender_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/delixfe/zapappender"
+	"go.uber.org/zap/zapcore"
+)
+
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+		IntValue    string `json:"intValue"`
+	} `json:"value"`
+}
+
+type otlpExport struct {
+	ResourceLogs []struct {
+		Resource struct {
+			Attributes []otlpAttribute `json:"attributes"`
+		} `json:"resource"`
+		ScopeLogs []struct {
+			LogRecords []struct {
+				TimeUnixNano   string          `json:"timeUnixNano"`
+				SeverityNumber int             `json:"severityNumber"`
+				SeverityText   string          `json:"severityText"`
+				Body           otlpAttribute   `json:"body"`
+				Attributes     []otlpAttribute `json:"attributes"`
+			} `json:"logRecords"`
+		} `json:"scopeLogs"`
+	} `json:"resourceLogs"`
+}
+
+func TestOTLP_json(t *testing.T) {
+	exports := make(chan otlpExport, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected content type %q", r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		var export otlpExport
+		if err := json.Unmarshal(body, &export); err != nil {
+			t.Errorf("%v\n%s", err, body)
+		}
+		exports <- export
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	a, err := zapappender.NewOTLP(server.URL, zapappender.OTLPConfig{
+		ResourceAttributes: map[string]string{"service.name": "shop"},
+	}, zapappender.HTTPFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Shutdown()
+
+	ts := time.Unix(1600000000, 123)
+	_, _ = a.Write([]byte("hello\n"), zapcore.Entry{
+		Level:      zapcore.WarnLevel,
+		Time:       ts,
+		LoggerName: "http",
+		Caller:     zapcore.NewEntryCaller(0, "/src/main.go", 42, true),
+	})
+	if err := a.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	export := <-exports
+	if len(export.ResourceLogs) != 1 || len(export.ResourceLogs[0].ScopeLogs) != 1 {
+		t.Fatalf("unexpected export %+v", export)
+	}
+	resource := export.ResourceLogs[0].Resource.Attributes
+	if len(resource) != 1 || resource[0].Key != "service.name" || resource[0].Value.StringValue != "shop" {
+		t.Errorf("unexpected resource attributes %+v", resource)
+	}
+	records := export.ResourceLogs[0].ScopeLogs[0].LogRecords
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+	record := records[0]
+	if record.TimeUnixNano != "1600000000000000123" || record.SeverityNumber != 13 || record.SeverityText != "WARN" {
+		t.Errorf("unexpected record %+v", record)
+	}
+	attributes := map[string]string{}
+	for _, attr := range record.Attributes {
+		attributes[attr.Key] = attr.Value.StringValue + attr.Value.IntValue
+	}
+	expected := map[string]string{"logger.name": "http", "code.filepath": "/src/main.go", "code.lineno": "42"}
+	for key, value := range expected {
+		if attributes[key] != value {
+			t.Errorf("expected attribute %s=%q, got %q", key, value, attributes[key])
+		}
+	}
+}
+
+func TestOTLP_protobuf(t *testing.T) {
+	bodies := make(chan []byte, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/x-protobuf" {
+			t.Errorf("unexpected content type %q", r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+	}))
+	defer server.Close()
+
+	a, err := zapappender.NewOTLP(server.URL, zapappender.OTLPConfig{Protobuf: true},
+		zapappender.HTTPFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Shutdown()
+
+	_, _ = a.Write([]byte("hello\n"), zapcore.Entry{Level: zapcore.ErrorLevel, Time: time.Unix(1, 0)})
+	if err := a.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	body := <-bodies
+	if !bytes.Contains(body, []byte("\x0a\x05hello")) || !bytes.Contains(body, []byte("\x10\x11")) {
+		t.Errorf("unexpected body %q", body)
+	}
+}
+
+func TestOTLP_partialSuccess_returnsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"partialSuccess":{"rejectedLogRecords":"1","errorMessage":"too old"}}`))
+	}))
+	defer server.Close()
+
+	a, err := zapappender.NewOTLP(server.URL, zapappender.OTLPConfig{}, zapappender.HTTPFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Shutdown()
+
+	_, _ = a.Write([]byte("hello\n"), zapcore.Entry{Time: time.Unix(1, 0)})
+	err = a.Sync()
+	if err == nil || !strings.Contains(err.Error(), "too old") {
+		t.Errorf("expected partial success error, got %v", err)
+	}
+}
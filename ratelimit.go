@@ -0,0 +1,238 @@
+package zapappender
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	_ SynchronizationAwareAppender = &RateLimit{}
+	_ FieldsAwareAppender          = &RateLimit{}
+)
+
+// RateLimit limits the rate of messages forwarded to the primary appender using token buckets.
+//
+// Messages exceeding the limit are dropped or forwarded to an overflow appender.
+// The number of suppressed messages is periodically reported through the primary appender.
+// By default, one bucket is shared by all messages. RateLimitByLoggerName and RateLimitByLevel
+// maintain a bucket per logger name or level.
+//
+// The summaries are reported by a go routine started by NewRateLimit.
+// Shutdown must be called to stop it once the RateLimit is no longer used.
+type RateLimit struct {
+	// readonly
+	primary        Appender
+	overflow       Appender
+	rate           float64
+	burst          float64
+	keyFn          func(ent zapcore.Entry) string
+	describeKeyFn  func(key string) string
+	summaryPeriod  time.Duration
+	summaryEncoder zapcore.Encoder
+	now            func() time.Time
+
+	// state
+	mu         sync.Mutex
+	buckets    map[string]*tokenBucket
+	summaryErr error // errors of the periodic summaries, reported by Sync
+	close      chan struct{}
+	closed     chan struct{} // closed when the summary go routine stopped
+	shutdown   int32         // incremented by Shutdown
+}
+
+type tokenBucket struct {
+	tokens     float64
+	last       time.Time
+	suppressed uint64
+}
+
+// NewRateLimit creates a RateLimit forwarding at most rate messages per second with bursts of up to burst messages.
+// primary is wrapped in a Synchronizing appender.
+// The returned RateLimit must be shut down with Shutdown.
+func NewRateLimit(primary Appender, rate float64, burst int, options ...RateLimitOption) (a *RateLimit, err error) {
+	if primary == nil {
+		return nil, errors.New("primary is required")
+	}
+	if rate <= 0 {
+		return nil, errors.New("rate must be positive")
+	}
+	if burst < 1 {
+		return nil, errors.New("burst must be at least 1")
+	}
+	a = &RateLimit{
+		primary: NewSynchronizing(primary),
+		rate:    rate,
+		burst:   float64(burst),
+		now:     time.Now,
+		buckets: make(map[string]*tokenBucket),
+	}
+
+	RateLimitGlobal().apply(a)
+	RateLimitOnLimitDropMessages().apply(a)
+	RateLimitSummaryPeriod(10 * time.Second).apply(a)
+
+	for _, option := range options {
+		err = option.apply(a)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	a.close = make(chan struct{})
+	a.closed = make(chan struct{})
+	go a.reportSummaries()
+
+	return a, nil
+}
+
+func (a *RateLimit) Write(p []byte, ent zapcore.Entry) (n int, err error) {
+	return a.WriteFields(p, ent, Fields{})
+}
+
+func (a *RateLimit) WriteFields(p []byte, ent zapcore.Entry, fields Fields) (n int, err error) {
+	if a.allow(ent) {
+		return writeWithFields(a.primary, p, ent, fields)
+	}
+	return writeWithFields(a.overflow, p, ent, fields)
+}
+
+func (a *RateLimit) WantsFields() bool {
+	return WantsFields(a.primary) || WantsFields(a.overflow)
+}
+
+func (a *RateLimit) allow(ent zapcore.Entry) bool {
+	key := a.keyFn(ent)
+	now := a.now()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	bucket, ok := a.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: a.burst, last: now}
+		a.buckets[key] = bucket
+	}
+	a.refill(bucket, now)
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true
+	}
+	bucket.suppressed++
+	return false
+}
+
+func (a *RateLimit) refill(bucket *tokenBucket, now time.Time) {
+	elapsed := now.Sub(bucket.last)
+	if elapsed <= 0 {
+		return
+	}
+	bucket.last = now
+	bucket.tokens += elapsed.Seconds() * a.rate
+	if bucket.tokens > a.burst {
+		bucket.tokens = a.burst
+	}
+}
+
+func (a *RateLimit) reportSummaries() {
+	defer close(a.closed)
+	ticker := time.NewTicker(a.summaryPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.writePeriodicSummaries()
+		case <-a.close:
+			return
+		}
+	}
+}
+
+// writePeriodicSummaries writes the summaries and keeps the error for the next Sync.
+func (a *RateLimit) writePeriodicSummaries() {
+	if err := a.writeSummaries(); err != nil {
+		a.mu.Lock()
+		a.summaryErr = multierr.Append(a.summaryErr, err)
+		a.mu.Unlock()
+	}
+}
+
+// writeSummaries reports and resets the suppressed counters.
+// Buckets that are full and did not suppress any messages are removed.
+func (a *RateLimit) writeSummaries() (err error) {
+	type summary struct {
+		key        string
+		suppressed uint64
+	}
+	now := a.now()
+	var summaries []summary
+
+	a.mu.Lock()
+	for key, bucket := range a.buckets {
+		if bucket.suppressed > 0 {
+			summaries = append(summaries, summary{key: key, suppressed: bucket.suppressed})
+			bucket.suppressed = 0
+			continue
+		}
+		a.refill(bucket, now)
+		if bucket.tokens >= a.burst {
+			delete(a.buckets, key)
+		}
+	}
+	a.mu.Unlock()
+
+	for _, s := range summaries {
+		msg := "suppressed " + strconv.FormatUint(s.suppressed, 10) + " messages"
+		if s.key != "" {
+			msg += " from " + a.describeKeyFn(s.key)
+		}
+		ent := zapcore.Entry{
+			Level:   zapcore.WarnLevel,
+			Time:    now,
+			Message: msg,
+		}
+		err = multierr.Append(err, writeSynthetic(a.primary, a.summaryEncoder, ent))
+	}
+	return err
+}
+
+// Sync reports the pending summaries and syncs the primary and overflow appenders.
+// Errors of the periodic summaries since the last Sync are returned as well.
+func (a *RateLimit) Sync() error {
+	a.mu.Lock()
+	periodicErr := a.summaryErr
+	a.summaryErr = nil
+	a.mu.Unlock()
+	err := a.writeSummaries()
+	return multierr.Combine(periodicErr, err, a.primary.Sync(), a.overflow.Sync())
+}
+
+func (a *RateLimit) Synchronized() bool {
+	return true
+}
+
+// Shutdown stops the periodic summary reporting and reports the pending summaries.
+// It blocks until a summary being reported concurrently is written or the provided context is cancelled,
+// in which case the pending summaries are not reported.
+func (a *RateLimit) Shutdown(ctx context.Context) {
+	if atomic.SwapInt32(&a.shutdown, 1) != 0 {
+		return // already called
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	close(a.close)
+	select {
+	case <-ctx.Done():
+		return
+	case <-a.closed:
+	}
+	_ = a.writeSummaries()
+}
@@ -0,0 +1,96 @@
+package zapappender
+
+import (
+	"errors"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+type RateLimitOption interface {
+	apply(*RateLimit) error
+}
+
+type rateLimitOptionsFunc func(*RateLimit) error
+
+func (f rateLimitOptionsFunc) apply(a *RateLimit) error {
+	return f(a)
+}
+
+// RateLimitGlobal shares one bucket between all messages.
+func RateLimitGlobal() RateLimitOption {
+	return RateLimitKeyFn(func(zapcore.Entry) string { return "" }, nil)
+}
+
+// RateLimitByLoggerName maintains a bucket per logger name.
+func RateLimitByLoggerName() RateLimitOption {
+	return RateLimitKeyFn(func(ent zapcore.Entry) string {
+		return ent.LoggerName
+	}, func(key string) string {
+		return "logger " + key
+	})
+}
+
+// RateLimitByLevel maintains a bucket per level.
+func RateLimitByLevel() RateLimitOption {
+	return RateLimitKeyFn(func(ent zapcore.Entry) string {
+		return ent.Level.String()
+	}, func(key string) string {
+		return "level " + key
+	})
+}
+
+// RateLimitKeyFn maintains a bucket per key returned by keyFn.
+// describeKeyFn is used to name the key in summaries, it might be nil.
+func RateLimitKeyFn(keyFn func(ent zapcore.Entry) string, describeKeyFn func(key string) string) RateLimitOption {
+	return rateLimitOptionsFunc(func(a *RateLimit) error {
+		if keyFn == nil {
+			return errors.New("keyFn must not be nil")
+		}
+		if describeKeyFn == nil {
+			describeKeyFn = func(key string) string { return key }
+		}
+		a.keyFn = keyFn
+		a.describeKeyFn = describeKeyFn
+		return nil
+	})
+}
+
+// RateLimitOnLimitForwardTo forwards messages exceeding the limit to overflow.
+// overflow is wrapped in a Synchronizing appender.
+func RateLimitOnLimitForwardTo(overflow Appender) RateLimitOption {
+	return rateLimitOptionsFunc(func(a *RateLimit) error {
+		if overflow == nil {
+			return errors.New("overflow must not be nil")
+		}
+		a.overflow = NewSynchronizing(overflow)
+		return nil
+	})
+}
+
+func RateLimitOnLimitDropMessages() RateLimitOption {
+	return rateLimitOptionsFunc(func(a *RateLimit) error {
+		a.overflow = NewDiscard()
+		return nil
+	})
+}
+
+// RateLimitSummaryPeriod sets the interval in which suppressed messages are reported.
+func RateLimitSummaryPeriod(period time.Duration) RateLimitOption {
+	return rateLimitOptionsFunc(func(a *RateLimit) error {
+		if period <= time.Duration(0) {
+			return errors.New("period must be positive")
+		}
+		a.summaryPeriod = period
+		return nil
+	})
+}
+
+// RateLimitSummaryEncoder encodes the summaries with enc.
+// Without an encoder, summaries are written as plain text lines.
+func RateLimitSummaryEncoder(enc zapcore.Encoder) RateLimitOption {
+	return rateLimitOptionsFunc(func(a *RateLimit) error {
+		a.summaryEncoder = enc
+		return nil
+	})
+}
@@ -0,0 +1,161 @@
+package zapappender
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/delixfe/zapappender/internal"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestRateLimit(t *testing.T) {
+	out := &internal.Buffer{}
+	overflow := &internal.Buffer{}
+	a, err := NewRateLimit(NewWriter(out), 1, 2,
+		RateLimitByLoggerName(),
+		RateLimitOnLimitForwardTo(NewWriter(overflow)),
+		RateLimitSummaryPeriod(time.Hour),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Shutdown(context.Background())
+
+	now := time.Unix(0, 0)
+	a.now = func() time.Time { return now }
+
+	write := func(logger string) {
+		t.Helper()
+		if _, err := a.Write([]byte(logger+"\n"), zapcore.Entry{LoggerName: logger}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 4; i++ {
+		write("crashing")
+	}
+	write("other")
+
+	now = now.Add(time.Second)
+	write("crashing")
+	write("crashing")
+
+	if err := a.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"crashing",
+		"crashing",
+		"other",
+		"crashing",
+		"suppressed 3 messages from logger crashing",
+	}
+	assertLines(t, expected, out.Lines(), "primary")
+	assertLines(t, []string{"crashing", "crashing", "crashing"}, overflow.Lines(), "overflow")
+
+	// counters are reset after reporting
+	if err := a.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	assertLines(t, expected, out.Lines(), "primary after second sync")
+}
+
+func TestRateLimit_periodicSummaryErr_returnedBySync(t *testing.T) {
+	errWrite := errors.New("write failed")
+	a, err := NewRateLimit(failingAppender{err: errWrite}, 1, 1, RateLimitSummaryPeriod(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Shutdown(context.Background())
+
+	now := time.Unix(0, 0)
+	a.now = func() time.Time { return now }
+
+	_, _ = a.Write([]byte("first\n"), zapcore.Entry{})
+	if _, err := a.Write([]byte("suppressed\n"), zapcore.Entry{}); err != nil {
+		t.Fatal(err)
+	}
+
+	a.writePeriodicSummaries()
+
+	if err := a.Sync(); !errors.Is(err, errWrite) {
+		t.Errorf("expected the periodic summary error, got %v", err)
+	}
+	if err := a.Sync(); err != nil {
+		t.Errorf("expected the error to be reported once, got %v", err)
+	}
+}
+
+func TestNewRateLimit_invalidArguments_returnsErr(t *testing.T) {
+	tests := []struct {
+		name    string
+		rate    float64
+		burst   int
+		options []RateLimitOption
+	}{
+		{name: "rate zero", rate: 0, burst: 1},
+		{name: "burst zero", rate: 1, burst: 0},
+		{name: "summary period zero", rate: 1, burst: 1, options: []RateLimitOption{RateLimitSummaryPeriod(0)}},
+		{name: "overflow nil", rate: 1, burst: 1, options: []RateLimitOption{RateLimitOnLimitForwardTo(nil)}},
+		{name: "keyFn nil", rate: 1, burst: 1, options: []RateLimitOption{RateLimitKeyFn(nil, nil)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewRateLimit(NewDiscard(), tt.rate, tt.burst, tt.options...)
+			if err == nil {
+				a.Shutdown(context.Background())
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestRateLimit_Shutdown_stopsReportingAndReportsPending(t *testing.T) {
+	out := &internal.Buffer{}
+	a, err := NewRateLimit(NewWriter(out), 1, 1, RateLimitSummaryPeriod(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = a.Write([]byte("first\n"), zapcore.Entry{})
+	_, _ = a.Write([]byte("suppressed\n"), zapcore.Entry{})
+
+	a.Shutdown(context.Background())
+
+	select {
+	case <-a.closed:
+	default:
+		t.Error("expected the summary go routine to be stopped")
+	}
+	assertLines(t, []string{"first", "suppressed 1 messages"}, out.Lines(), "primary")
+
+	// a second call is a no-op
+	a.Shutdown(context.Background())
+	assertLines(t, []string{"first", "suppressed 1 messages"}, out.Lines(), "primary after second shutdown")
+}
+
+func assertLines(t *testing.T, expected, actual []string, msg string) {
+	t.Helper()
+	if len(expected) != len(actual) {
+		t.Fatalf("%s: expected %d lines, got %d: %q", msg, len(expected), len(actual), actual)
+	}
+	for i := range expected {
+		if expected[i] != actual[i] {
+			t.Errorf("%s: line %d:\n\texpected: %q\n\tactual:   %q", msg, i, expected[i], actual[i])
+		}
+	}
+}
+
+type failingAppender struct {
+	err error
+}
+
+func (a failingAppender) Write(p []byte, ent zapcore.Entry) (int, error) {
+	return 0, a.err
+}
+
+func (a failingAppender) Sync() error {
+	return nil
+}
@@ -0,0 +1,477 @@
+package zapappender
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/delixfe/zapappender/internal/bufferpool"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// RedactMatchFn appends the [start, end) byte ranges of all sensitive values in p to dst.
+type RedactMatchFn func(dst [][2]int, p []byte) [][2]int
+
+var (
+	_ SynchronizationAwareAppender = &Redacting{}
+	_ FieldsAwareAppender          = &Redacting{}
+)
+
+// Redacting replaces sensitive values in the encoded message before forwarding it.
+//
+// Each message is scanned by all matchers. Matches are replaced with a mask or, with RedactingKeyedHash,
+// with a keyed hash that allows to correlate values without disclosing them.
+// The replacement is inserted as is; it must not break the encoding, e.g. by containing quotes for JSON.
+//
+// If the primary appender wants fields (see FieldsAware), string, byte and integer field values are redacted
+// as well. A value containing a match is replaced by its redacted string.
+type Redacting struct {
+	// readonly
+	primary  Appender
+	matchers []RedactMatchFn
+	mask     string
+	hashKey  []byte
+}
+
+type RedactingOption interface {
+	apply(*Redacting) error
+}
+
+type redactingOptionsFunc func(*Redacting) error
+
+func (f redactingOptionsFunc) apply(a *Redacting) error {
+	return f(a)
+}
+
+// RedactingMask sets the replacement for matches. The default is "[REDACTED]".
+func RedactingMask(mask string) RedactingOption {
+	return redactingOptionsFunc(func(a *Redacting) error {
+		a.mask = mask
+		return nil
+	})
+}
+
+// RedactingKeyedHash replaces matches with "[REDACTED:<hash>]", where hash is the hex encoded first
+// 8 bytes of the HMAC-SHA256 of the value with key.
+func RedactingKeyedHash(key []byte) RedactingOption {
+	return redactingOptionsFunc(func(a *Redacting) error {
+		if len(key) == 0 {
+			return errors.New("key must not be empty")
+		}
+		a.hashKey = append([]byte(nil), key...)
+		return nil
+	})
+}
+
+// NewRedacting creates a Redacting appender. RedactDefaults returns the well-known detectors.
+func NewRedacting(primary Appender, matchers []RedactMatchFn, options ...RedactingOption) (a *Redacting, err error) {
+	if primary == nil {
+		return nil, errors.New("primary is required")
+	}
+	if len(matchers) == 0 {
+		return nil, errors.New("at least one matcher is required")
+	}
+	for _, match := range matchers {
+		if match == nil {
+			return nil, errors.New("matcher must not be nil")
+		}
+	}
+	a = &Redacting{
+		primary:  primary,
+		matchers: append([]RedactMatchFn(nil), matchers...),
+		mask:     "[REDACTED]",
+	}
+	for _, option := range options {
+		err = option.apply(a)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+func (a *Redacting) Write(p []byte, ent zapcore.Entry) (n int, err error) {
+	return a.WriteFields(p, ent, Fields{})
+}
+
+func (a *Redacting) WriteFields(p []byte, ent zapcore.Entry, fields Fields) (n int, err error) {
+	if fields.Len() > 0 {
+		fields = Fields{m: a.redactFieldMap(fields.merged())}
+	}
+	buf := bufferpool.Get()
+	defer buf.Free()
+	if !a.appendRedacted(buf, p) {
+		return writeWithFields(a.primary, p, ent, fields)
+	}
+	if _, err = writeWithFields(a.primary, buf.Bytes(), ent, fields); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (a *Redacting) WantsFields() bool {
+	return WantsFields(a.primary)
+}
+
+// appendRedacted appends p with all matches replaced to buf and returns whether there were matches.
+// Without matches, nothing is appended.
+func (a *Redacting) appendRedacted(buf *buffer.Buffer, p []byte) bool {
+	var ranges [][2]int
+	for _, match := range a.matchers {
+		ranges = match(ranges, p)
+	}
+	if len(ranges) == 0 {
+		return false
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	pos := 0
+	for i := 0; i < len(ranges); i++ {
+		start, end := ranges[i][0], ranges[i][1]
+		if start < pos {
+			start = pos
+		}
+		// merge overlapping matches
+		for i+1 < len(ranges) && ranges[i+1][0] < end {
+			i++
+			if ranges[i][1] > end {
+				end = ranges[i][1]
+			}
+		}
+		if start >= end {
+			continue
+		}
+		_, _ = buf.Write(p[pos:start])
+		a.appendReplacement(buf, p[start:end])
+		pos = end
+	}
+	_, _ = buf.Write(p[pos:])
+	return true
+}
+
+// redactFieldMap returns a copy of m with the sensitive values redacted.
+// The values of the snapshot are immutable, so unchanged values are shared.
+func (a *Redacting) redactFieldMap(m map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		redacted[key] = a.redactFieldValue(value)
+	}
+	return redacted
+}
+
+func (a *Redacting) redactFieldValue(value interface{}) interface{} {
+	var text []byte
+	switch v := value.(type) {
+	case string:
+		text = []byte(v)
+	case []byte:
+		text = v
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		// e.g. card numbers logged as integer
+		text = []byte(fmt.Sprint(v))
+	case map[string]interface{}:
+		return a.redactFieldMap(v)
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i := range v {
+			redacted[i] = a.redactFieldValue(v[i])
+		}
+		return redacted
+	default:
+		return value
+	}
+	buf := bufferpool.Get()
+	defer buf.Free()
+	if !a.appendRedacted(buf, text) {
+		return value
+	}
+	return buf.String()
+}
+
+func (a *Redacting) appendReplacement(buf *buffer.Buffer, value []byte) {
+	if a.hashKey == nil {
+		buf.AppendString(a.mask)
+		return
+	}
+	mac := hmac.New(sha256.New, a.hashKey)
+	_, _ = mac.Write(value)
+	buf.AppendString("[REDACTED:")
+	buf.AppendString(hex.EncodeToString(mac.Sum(nil)[:8]))
+	buf.AppendString("]")
+}
+
+func (a *Redacting) Sync() error {
+	return a.primary.Sync()
+}
+
+func (a *Redacting) Synchronized() bool {
+	return Synchronized(a.primary)
+}
+
+// RedactDefaults returns all well-known detectors: emails, IBANs, card numbers, JWTs, AWS access keys and bearer tokens.
+func RedactDefaults() []RedactMatchFn {
+	return []RedactMatchFn{
+		RedactEmail(),
+		RedactIBAN(),
+		RedactCardNumber(),
+		RedactJWT(),
+		RedactAWSAccessKey(),
+		RedactBearerToken(),
+	}
+}
+
+// RedactRegexp redacts all matches of re. If re has a capturing group, only the first group is redacted.
+func RedactRegexp(re *regexp.Regexp) RedactMatchFn {
+	return redactRegexp(re, nil, nil)
+}
+
+// redactRegexp creates a matcher running re only if prefilter reports a possible match
+// and keeping only matches accepted by validate.
+func redactRegexp(re *regexp.Regexp, prefilter func(p []byte) bool, validate func(match []byte) bool) RedactMatchFn {
+	group := 0
+	if re.NumSubexp() > 0 {
+		group = 1
+	}
+	return func(dst [][2]int, p []byte) [][2]int {
+		if prefilter != nil && !prefilter(p) {
+			return dst
+		}
+		for _, loc := range re.FindAllSubmatchIndex(p, -1) {
+			start, end := loc[2*group], loc[2*group+1]
+			if start < 0 || start == end {
+				continue
+			}
+			if validate != nil && !validate(p[start:end]) {
+				continue
+			}
+			dst = append(dst, [2]int{start, end})
+		}
+		return dst
+	}
+}
+
+var redactEmailRegexp = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// RedactEmail redacts email addresses.
+func RedactEmail() RedactMatchFn {
+	return redactRegexp(redactEmailRegexp, func(p []byte) bool {
+		return bytes.IndexByte(p, '@') >= 0
+	}, nil)
+}
+
+var redactIBANRegexp = regexp.MustCompile(`\b[A-Z]{2}[0-9]{2}(?: ?[A-Z0-9]){11,30}\b`)
+
+// RedactIBAN redacts international bank account numbers with a valid checksum,
+// optionally grouped with spaces. A following word is not part of the number, e.g. in "DE89 ... 00 EUR".
+func RedactIBAN() RedactMatchFn {
+	return func(dst [][2]int, p []byte) [][2]int {
+		if !containsIBANStart(p) {
+			return dst
+		}
+		for pos := 0; pos < len(p); {
+			loc := redactIBANRegexp.FindIndex(p[pos:])
+			if loc == nil {
+				break
+			}
+			start := pos + loc[0]
+			if start > 0 && isAlphaNumeric(p[start-1]) {
+				// \b matched at the start of the slice within a word
+				pos = start + 1
+				continue
+			}
+			end := ibanEnd(p[start : pos+loc[1]])
+			if end == -1 {
+				pos = start + 1
+				continue
+			}
+			dst = append(dst, [2]int{start, start + end})
+			pos = start + end
+		}
+		return dst
+	}
+}
+
+func containsIBANStart(p []byte) bool {
+	for i := 0; i+3 < len(p); i++ {
+		if isUpper(p[i]) && isUpper(p[i+1]) && isDigit(p[i+2]) && isDigit(p[i+3]) {
+			return true
+		}
+	}
+	return false
+}
+
+// ibanEnd returns the length of the longest valid IBAN at the start of candidate or -1.
+// The regular expression also matches the words following an IBAN, the shorter candidates end before a space.
+func ibanEnd(candidate []byte) int {
+	if validIBAN(candidate) {
+		return len(candidate)
+	}
+	for i := len(candidate) - 1; i >= 15; i-- {
+		if candidate[i] == ' ' && validIBAN(candidate[:i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// validIBAN verifies the ISO 13616 mod 97 checksum.
+func validIBAN(iban []byte) bool {
+	compact := make([]byte, 0, len(iban))
+	for _, c := range iban {
+		if c != ' ' {
+			compact = append(compact, c)
+		}
+	}
+	if len(compact) < 15 || len(compact) > 34 {
+		return false
+	}
+	rearranged := append(compact[4:len(compact):len(compact)], compact[:4]...)
+	remainder := 0
+	for _, c := range rearranged {
+		switch {
+		case isDigit(c):
+			remainder = (remainder*10 + int(c-'0')) % 97
+		case isUpper(c):
+			remainder = (remainder*100 + int(c-'A') + 10) % 97
+		default:
+			return false
+		}
+	}
+	return remainder == 1
+}
+
+// RedactCardNumber redacts payment card numbers of 13 to 19 digits with a valid Luhn checksum,
+// optionally grouped with spaces or dashes. Only numbers starting with 2 to 6 are considered,
+// which excludes the current Unix timestamps in milliseconds.
+func RedactCardNumber() RedactMatchFn {
+	return func(dst [][2]int, p []byte) [][2]int {
+		for i := 0; i < len(p); i++ {
+			if !isDigit(p[i]) || (i > 0 && isAlphaNumeric(p[i-1])) {
+				continue
+			}
+			if p[i] < '2' || p[i] > '6' {
+				// skip the rest of the number
+				for i+1 < len(p) && isDigit(p[i+1]) {
+					i++
+				}
+				continue
+			}
+			digits, end := 0, i
+			for j := i; j < len(p); j++ {
+				if isDigit(p[j]) {
+					digits++
+					end = j + 1
+					continue
+				}
+				if (p[j] == ' ' || p[j] == '-') && j+1 < len(p) && isDigit(p[j+1]) && isDigit(p[j-1]) {
+					continue
+				}
+				break
+			}
+			if end < len(p) && isAlphaNumeric(p[end]) {
+				i = end
+				continue
+			}
+			if cardEnd := cardNumberEnd(p[i:end], digits); cardEnd != -1 {
+				// continue after the card number, the rest may contain another one
+				end = i + cardEnd
+				dst = append(dst, [2]int{i, end})
+			}
+			i = end
+		}
+		return dst
+	}
+}
+
+// cardNumberEnd returns the length of the longest card number at the start of candidate or -1.
+// The scan also joins numbers following a card number, e.g. in "4111 1111 1111 1111 2 items",
+// so the shorter candidates ending before a separator are checked as well.
+func cardNumberEnd(candidate []byte, digits int) int {
+	end := len(candidate)
+	for {
+		if digits >= 13 && digits <= 19 && validLuhn(candidate[:end]) {
+			return end
+		}
+		// cut at the previous separator
+		for end > 0 && isDigit(candidate[end-1]) {
+			end--
+			digits--
+		}
+		if end == 0 || digits < 13 {
+			return -1
+		}
+		end-- // the separator
+	}
+}
+
+// validLuhn verifies the Luhn checksum of the digits in number, skipping separators.
+func validLuhn(number []byte) bool {
+	sum, double := 0, false
+	for i := len(number) - 1; i >= 0; i-- {
+		if !isDigit(number[i]) {
+			continue
+		}
+		d := int(number[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+var redactJWTRegexp = regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]+\.eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`)
+
+// RedactJWT redacts JSON web tokens.
+func RedactJWT() RedactMatchFn {
+	return redactRegexp(redactJWTRegexp, func(p []byte) bool {
+		return bytes.Contains(p, []byte("eyJ"))
+	}, nil)
+}
+
+var redactAWSAccessKeyRegexp = regexp.MustCompile(`\b(?:AKIA|ASIA|AIDA|AROA)[A-Z0-9]{16}\b`)
+
+// RedactAWSAccessKey redacts AWS access key ids.
+func RedactAWSAccessKey() RedactMatchFn {
+	return redactRegexp(redactAWSAccessKeyRegexp, func(p []byte) bool {
+		return bytes.Contains(p, []byte("AKIA")) || bytes.Contains(p, []byte("ASIA")) ||
+			bytes.Contains(p, []byte("AIDA")) || bytes.Contains(p, []byte("AROA"))
+	}, nil)
+}
+
+var redactBearerTokenRegexp = regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`)
+
+// RedactBearerToken redacts the token of bearer authorizations, e.g. of "Authorization: Bearer <token>".
+func RedactBearerToken() RedactMatchFn {
+	return redactRegexp(redactBearerTokenRegexp, func(p []byte) bool {
+		for i := 0; i+6 <= len(p); i++ {
+			if p[i]|0x20 == 'b' && p[i+1]|0x20 == 'e' && p[i+2]|0x20 == 'a' &&
+				p[i+3]|0x20 == 'r' && p[i+4]|0x20 == 'e' && p[i+5]|0x20 == 'r' {
+				return true
+			}
+		}
+		return false
+	}, nil)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isUpper(c byte) bool {
+	return c >= 'A' && c <= 'Z'
+}
+
+func isAlphaNumeric(c byte) bool {
+	return isDigit(c) || isUpper(c) || (c >= 'a' && c <= 'z')
+}
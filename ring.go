@@ -0,0 +1,78 @@
+package zapappender
+
+import (
+	"github.com/delixfe/zapappender/internal/bufferpool"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+type ringItem struct {
+	buf    *buffer.Buffer
+	ent    zapcore.Entry
+	fields Fields
+}
+
+// entryRing retains copies of the most recent messages.
+// It is limited by the number of messages and optionally by their total size in bytes.
+// The newest message is always retained, even if it alone exceeds the size limit.
+//
+// entryRing is not safe for concurrent use.
+type entryRing struct {
+	items    []ringItem
+	start    int
+	count    int
+	size     int
+	maxBytes int
+}
+
+func newEntryRing(maxEntries, maxBytes int) *entryRing {
+	return &entryRing{
+		items:    make([]ringItem, maxEntries),
+		maxBytes: maxBytes,
+	}
+}
+
+func (r *entryRing) push(p []byte, ent zapcore.Entry, fields Fields) {
+	if r.count == len(r.items) {
+		r.evict()
+	}
+	if r.maxBytes > 0 {
+		for r.count > 0 && r.size+len(p) > r.maxBytes {
+			r.evict()
+		}
+	}
+	buf := bufferpool.Get()
+	_, _ = buf.Write(p)
+	r.items[(r.start+r.count)%len(r.items)] = ringItem{buf: buf, ent: ent, fields: fields}
+	r.count++
+	r.size += len(p)
+}
+
+// evict removes the oldest message
+func (r *entryRing) evict() {
+	item := &r.items[r.start]
+	r.size -= item.buf.Len()
+	item.buf.Free()
+	*item = ringItem{}
+	r.start = (r.start + 1) % len(r.items)
+	r.count--
+}
+
+// each calls fn for all messages from the oldest to the newest
+func (r *entryRing) each(fn func(item ringItem)) {
+	for i := 0; i < r.count; i++ {
+		fn(r.items[(r.start+i)%len(r.items)])
+	}
+}
+
+func (r *entryRing) len() int {
+	return r.count
+}
+
+// reset removes all messages
+func (r *entryRing) reset() {
+	for r.count > 0 {
+		r.evict()
+	}
+	r.start = 0
+}
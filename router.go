@@ -0,0 +1,193 @@
+package zapappender
+
+import (
+	"errors"
+	"path"
+	"regexp"
+	"strings"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+// RouteMatchFn decides whether an entry is handled by a Route.
+type RouteMatchFn func(ent zapcore.Entry) bool
+
+// Route dispatches all entries accepted by Match to Appender.
+// If Continue is set, the following routes and finally the default route are evaluated as well after a match.
+type Route struct {
+	Match    RouteMatchFn
+	Appender Appender
+	Continue bool
+}
+
+var _ SynchronizationAwareAppender = &Router{}
+
+// Router dispatches each message to the appenders of the matching routes.
+//
+// The routes are evaluated in order. Evaluation stops at the first matching route unless
+// its Continue flag is set. If evaluation was not stopped, the message is forwarded to the default appender.
+type Router struct {
+	routes       []Route
+	defaultRoute Appender
+	synchronized bool
+	wantsFields  bool
+}
+
+// NewRouter creates a Router.
+// defaultRoute might be nil; messages reaching it are then dropped.
+func NewRouter(routes []Route, defaultRoute Appender) (*Router, error) {
+	synchronized, wantsFields := true, false
+	for _, route := range routes {
+		if route.Match == nil {
+			return nil, errors.New("route match must not be nil")
+		}
+		if route.Appender == nil {
+			return nil, errors.New("route appender must not be nil")
+		}
+		synchronized = synchronized && Synchronized(route.Appender)
+		wantsFields = wantsFields || WantsFields(route.Appender)
+	}
+	if defaultRoute != nil {
+		synchronized = synchronized && Synchronized(defaultRoute)
+		wantsFields = wantsFields || WantsFields(defaultRoute)
+	}
+	return &Router{
+		routes:       append([]Route(nil), routes...),
+		defaultRoute: defaultRoute,
+		synchronized: synchronized,
+		wantsFields:  wantsFields,
+	}, nil
+}
+
+func (a *Router) Write(p []byte, ent zapcore.Entry) (n int, err error) {
+	return a.WriteFields(p, ent, Fields{})
+}
+
+func (a *Router) WriteFields(p []byte, ent zapcore.Entry, fields Fields) (n int, err error) {
+	stopped := false
+	for i := range a.routes {
+		route := &a.routes[i]
+		if !route.Match(ent) {
+			continue
+		}
+		_, writeErr := writeWithFields(route.Appender, p, ent, fields)
+		err = multierr.Append(err, writeErr)
+		if !route.Continue {
+			stopped = true
+			break
+		}
+	}
+	if !stopped && a.defaultRoute != nil {
+		_, writeErr := writeWithFields(a.defaultRoute, p, ent, fields)
+		err = multierr.Append(err, writeErr)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (a *Router) Sync() (err error) {
+	for _, route := range a.routes {
+		err = multierr.Append(err, route.Appender.Sync())
+	}
+	if a.defaultRoute != nil {
+		err = multierr.Append(err, a.defaultRoute.Sync())
+	}
+	return err
+}
+
+// WantsFields returns true if any route appender wants fields.
+func (a *Router) WantsFields() bool {
+	return a.wantsFields
+}
+
+// Synchronized returns true if all route appenders are synchronized.
+func (a *Router) Synchronized() bool {
+	return a.synchronized
+}
+
+// RouteLevelRange matches entries with a level between min and max, both inclusive.
+func RouteLevelRange(min, max zapcore.Level) RouteMatchFn {
+	return func(ent zapcore.Entry) bool {
+		return ent.Level >= min && ent.Level <= max
+	}
+}
+
+// RouteLevelEnabled matches entries whose level is enabled by enab.
+// Passing a zap.AtomicLevel allows to adjust the route at runtime.
+func RouteLevelEnabled(enab zapcore.LevelEnabler) RouteMatchFn {
+	return func(ent zapcore.Entry) bool {
+		return enab.Enabled(ent.Level)
+	}
+}
+
+// RouteLoggerNamePrefix matches entries whose logger name starts with prefix.
+func RouteLoggerNamePrefix(prefix string) RouteMatchFn {
+	return func(ent zapcore.Entry) bool {
+		return strings.HasPrefix(ent.LoggerName, prefix)
+	}
+}
+
+// RouteLoggerNameGlob matches the logger name against a shell pattern as supported by path.Match.
+func RouteLoggerNameGlob(pattern string) (RouteMatchFn, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	return func(ent zapcore.Entry) bool {
+		matched, _ := path.Match(pattern, ent.LoggerName)
+		return matched
+	}, nil
+}
+
+// RouteCallerFileGlob matches the caller file against a shell pattern as supported by path.Match.
+// Patterns without a slash are matched against the base name of the file only.
+func RouteCallerFileGlob(pattern string) (RouteMatchFn, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	baseOnly := !strings.Contains(pattern, "/")
+	return func(ent zapcore.Entry) bool {
+		if !ent.Caller.Defined {
+			return false
+		}
+		file := ent.Caller.File
+		if baseOnly {
+			file = path.Base(file)
+		}
+		matched, _ := path.Match(pattern, file)
+		return matched
+	}, nil
+}
+
+// RouteMessageRegexp matches entries whose message matches re.
+func RouteMessageRegexp(re *regexp.Regexp) RouteMatchFn {
+	return func(ent zapcore.Entry) bool {
+		return re.MatchString(ent.Message)
+	}
+}
+
+// RouteAll matches if all matchers match.
+func RouteAll(matchers ...RouteMatchFn) RouteMatchFn {
+	return func(ent zapcore.Entry) bool {
+		for _, match := range matchers {
+			if !match(ent) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// RouteAny matches if at least one of the matchers matches.
+func RouteAny(matchers ...RouteMatchFn) RouteMatchFn {
+	return func(ent zapcore.Entry) bool {
+		for _, match := range matchers {
+			if match(ent) {
+				return true
+			}
+		}
+		return false
+	}
+}
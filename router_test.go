@@ -0,0 +1,88 @@
+package zapappender_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/delixfe/zapappender"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestRouter(t *testing.T) {
+	errs, errsWritten := NewWriteCountingAppender()
+	access, accessWritten := NewWriteCountingAppender()
+	audit, auditWritten := NewWriteCountingAppender()
+	rest, restWritten := NewWriteCountingAppender()
+
+	accessGlob, err := zapappender.RouteLoggerNameGlob("http.access*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router, err := zapappender.NewRouter([]zapappender.Route{
+		{Match: zapappender.RouteLevelRange(zapcore.ErrorLevel, zapcore.FatalLevel), Appender: errs, Continue: true},
+		{Match: zapappender.RouteMessageRegexp(regexp.MustCompile("^audit:")), Appender: audit},
+		{Match: accessGlob, Appender: access},
+	}, rest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(ent zapcore.Entry) {
+		t.Helper()
+		if _, err := router.Write([]byte("x"), ent); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(zapcore.Entry{Level: zapcore.InfoLevel, LoggerName: "app"})
+	write(zapcore.Entry{Level: zapcore.InfoLevel, LoggerName: "http.access"})
+	write(zapcore.Entry{Level: zapcore.ErrorLevel, LoggerName: "http.access.v2"})
+	write(zapcore.Entry{Level: zapcore.ErrorLevel, LoggerName: "app"})
+	write(zapcore.Entry{Level: zapcore.InfoLevel, Message: "audit: login"})
+
+	AssertWrittenEquals(t, 2, errsWritten, "errs")
+	AssertWrittenEquals(t, 2, accessWritten, "access")
+	AssertWrittenEquals(t, 1, auditWritten, "audit")
+	AssertWrittenEquals(t, 2, restWritten, "default")
+}
+
+func TestRouter_noDefault_drops(t *testing.T) {
+	router, err := zapappender.NewRouter([]zapappender.Route{
+		{Match: zapappender.RouteLoggerNamePrefix("db"), Appender: NewTestFailOnWriteAppender(t)},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := router.Write([]byte("x"), zapcore.Entry{LoggerName: "app"})
+	if err != nil || n != 1 {
+		t.Errorf("expected a silent drop, got n=%d err=%v", n, err)
+	}
+}
+
+func TestRouteCallerFileGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		file    string
+		want    bool
+	}{
+		{pattern: "handler_*.go", file: "/src/app/http/handler_user.go", want: true},
+		{pattern: "*/http/*.go", file: "/src/app/http/handler_user.go", want: false},
+		{pattern: "/src/app/http/*.go", file: "/src/app/http/handler_user.go", want: true},
+		{pattern: "db.go", file: "/src/app/http/handler_user.go", want: false},
+	}
+	for _, tt := range tests {
+		match, err := zapappender.RouteCallerFileGlob(tt.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ent := zapcore.Entry{Caller: zapcore.NewEntryCaller(0, tt.file, 1, true)}
+		if got := match(ent); got != tt.want {
+			t.Errorf("pattern %q on %q: got %v, want %v", tt.pattern, tt.file, got, tt.want)
+		}
+	}
+
+	if _, err := zapappender.RouteCallerFileGlob("["); err == nil {
+		t.Error("expected an error for a malformed pattern")
+	}
+}
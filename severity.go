@@ -0,0 +1,43 @@
+package zapappender
+
+import "go.uber.org/zap/zapcore"
+
+// syslogSeverity maps a level to the syslog severity as defined by RFC 5424.
+func syslogSeverity(lvl zapcore.Level) int {
+	switch {
+	case lvl <= zapcore.DebugLevel:
+		return 7 // debug
+	case lvl == zapcore.InfoLevel:
+		return 6 // informational
+	case lvl == zapcore.WarnLevel:
+		return 4 // warning
+	case lvl == zapcore.ErrorLevel:
+		return 3 // error
+	case lvl == zapcore.DPanicLevel:
+		return 2 // critical
+	case lvl == zapcore.PanicLevel:
+		return 1 // alert
+	default:
+		return 0 // emergency
+	}
+}
+
+// siemSeverity maps a level to the 0-10 severity scale of CEF and LEEF.
+func siemSeverity(lvl zapcore.Level) int {
+	switch {
+	case lvl <= zapcore.DebugLevel:
+		return 1
+	case lvl == zapcore.InfoLevel:
+		return 3
+	case lvl == zapcore.WarnLevel:
+		return 5
+	case lvl == zapcore.ErrorLevel:
+		return 7
+	case lvl == zapcore.DPanicLevel:
+		return 8
+	case lvl == zapcore.PanicLevel:
+		return 9
+	default:
+		return 10
+	}
+}
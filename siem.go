@@ -0,0 +1,174 @@
+package zapappender
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/delixfe/zapappender/internal/bufferpool"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// SIEMEvent identifies an event in the CEF and LEEF headers.
+// Empty fields fall back to the configured defaults.
+type SIEMEvent struct {
+	Vendor  string
+	Product string
+	Version string
+	// ID is the CEF signature id or the LEEF event id. Defaults to the logger name or "zap".
+	ID string
+	// Name is the CEF event name. Defaults to the entry message or the ID. Ignored by LEEF.
+	Name string
+}
+
+// SIEMEventFn derives the event identification from an entry.
+type SIEMEventFn func(ent zapcore.Entry) SIEMEvent
+
+// CEFConfig configures the CEF envelope.
+type CEFConfig struct {
+	Vendor  string
+	Product string
+	Version string
+	// EventFn optionally overrides the header fields per entry.
+	EventFn SIEMEventFn
+}
+
+// LEEFConfig configures the LEEF envelope.
+type LEEFConfig struct {
+	Vendor  string
+	Product string
+	Version string
+	// Delimiter separates the attributes. Defaults to tab.
+	Delimiter byte
+	// EventFn optionally overrides the header fields per entry.
+	EventFn SIEMEventFn
+}
+
+var (
+	// siemHeaderEscaper escapes CEF and LEEF header fields, which must not contain line breaks.
+	siemHeaderEscaper = newByteEscaper(map[byte]string{'\\': `\\`, '|': `\|`, '\n': " ", '\r': " "})
+	cefValueEscaper   = newByteEscaper(map[byte]string{'\\': `\\`, '=': `\=`, '\n': `\n`, '\r': `\r`})
+)
+
+// siemEvent resolves the event of ent against the static defaults.
+func siemEvent(fn SIEMEventFn, defaults SIEMEvent, ent zapcore.Entry) SIEMEvent {
+	var event SIEMEvent
+	if fn != nil {
+		event = fn(ent)
+	}
+	if event.Vendor == "" {
+		event.Vendor = defaults.Vendor
+	}
+	if event.Product == "" {
+		event.Product = defaults.Product
+	}
+	if event.Version == "" {
+		event.Version = defaults.Version
+	}
+	if event.ID == "" {
+		event.ID = ent.LoggerName
+		if event.ID == "" {
+			event.ID = "zap"
+		}
+	}
+	if event.Name == "" {
+		event.Name = ent.Message
+		if event.Name == "" {
+			event.Name = event.ID
+		}
+	}
+	return event
+}
+
+// NewCEFEnvelopingFn creates an EnvelopingFn formatting messages as ArcSight Common Event Format:
+//
+//	CEF:0|Vendor|Product|Version|SignatureID|Name|Severity|rt=... msg=...
+//
+// The level is mapped to the severity 1-10. The encoded message without its line ending becomes the msg
+// extension, logger name and caller are added as the custom strings cs1 and cs2.
+// The record is terminated with a newline if the encoded message was.
+func NewCEFEnvelopingFn(config CEFConfig) EnvelopingFn {
+	defaults := SIEMEvent{Vendor: config.Vendor, Product: config.Product, Version: config.Version}
+	return func(p []byte, ent zapcore.Entry, output *buffer.Buffer) error {
+		event := siemEvent(config.EventFn, defaults, ent)
+		output.AppendString("CEF:0|")
+		for _, field := range [...]string{event.Vendor, event.Product, event.Version, event.ID, event.Name} {
+			siemHeaderEscaper.appendString(output, field)
+			output.AppendByte('|')
+		}
+		output.AppendInt(int64(siemSeverity(ent.Level)))
+		output.AppendString("|rt=")
+		output.AppendInt(ent.Time.UnixNano() / int64(time.Millisecond))
+		if ent.LoggerName != "" {
+			output.AppendString(" cs1Label=logger cs1=")
+			cefValueEscaper.appendString(output, ent.LoggerName)
+		}
+		if ent.Caller.Defined {
+			output.AppendString(" cs2Label=caller cs2=")
+			caller := bufferpool.Get()
+			appendTrimmedCaller(caller, ent.Caller)
+			cefValueEscaper.appendBytes(output, caller.Bytes())
+			caller.Free()
+		}
+		msg := trimNewline(p)
+		output.AppendString(" msg=")
+		cefValueEscaper.appendBytes(output, msg)
+		if len(msg) < len(p) {
+			output.AppendByte('\n')
+		}
+		return nil
+	}
+}
+
+// NewLEEFEnvelopingFn creates an EnvelopingFn formatting messages as IBM Log Event Extended Format 2.0:
+//
+//	LEEF:2.0|Vendor|Product|Version|EventID|xHH|devTime=...<delimiter>sev=...<delimiter>msg=...
+//
+// The level is mapped to sev 1-10, the logger name is added as cat. The encoded message without
+// its line ending becomes the msg attribute.
+// The record is terminated with a newline if the encoded message was.
+func NewLEEFEnvelopingFn(config LEEFConfig) (EnvelopingFn, error) {
+	delimiter := config.Delimiter
+	if delimiter == 0 {
+		delimiter = '\t'
+	}
+	if delimiter >= 0x80 || strings.IndexByte("=|\\\r\n", delimiter) >= 0 {
+		return nil, errors.New("invalid LEEF delimiter")
+	}
+	valueEscapes := map[byte]string{'\\': `\\`, '\n': `\n`, '\r': `\r`, '\t': `\t`}
+	if _, ok := valueEscapes[delimiter]; !ok {
+		valueEscapes[delimiter] = `\` + string(delimiter)
+	}
+	valueEscaper := newByteEscaper(valueEscapes)
+	delimiterHex := string([]byte{'x', _hex[delimiter>>4], _hex[delimiter&0xF]})
+
+	defaults := SIEMEvent{Vendor: config.Vendor, Product: config.Product, Version: config.Version}
+	return func(p []byte, ent zapcore.Entry, output *buffer.Buffer) error {
+		event := siemEvent(config.EventFn, defaults, ent)
+		output.AppendString("LEEF:2.0|")
+		for _, field := range [...]string{event.Vendor, event.Product, event.Version, event.ID} {
+			siemHeaderEscaper.appendString(output, field)
+			output.AppendByte('|')
+		}
+		output.AppendString(delimiterHex)
+		output.AppendString("|devTime=")
+		output.AppendTime(ent.Time.UTC(), "Jan 02 2006 15:04:05.000 MST")
+		output.AppendByte(delimiter)
+		output.AppendString("sev=")
+		output.AppendInt(int64(siemSeverity(ent.Level)))
+		if ent.LoggerName != "" {
+			output.AppendByte(delimiter)
+			output.AppendString("cat=")
+			valueEscaper.appendString(output, ent.LoggerName)
+		}
+		msg := trimNewline(p)
+		output.AppendByte(delimiter)
+		output.AppendString("msg=")
+		valueEscaper.appendBytes(output, msg)
+		if len(msg) < len(p) {
+			output.AppendByte('\n')
+		}
+		return nil
+	}, nil
+}
@@ -0,0 +1,68 @@
+package zapappender_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/delixfe/zapappender"
+	"github.com/delixfe/zapappender/internal"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestCEFEnvelopingFn(t *testing.T) {
+	out := &internal.Buffer{}
+	a := zapappender.NewEnveloping(zapappender.NewWriter(out), zapappender.NewCEFEnvelopingFn(zapappender.CEFConfig{
+		Vendor:  "Acme|Corp",
+		Product: "shop",
+		Version: "1.0",
+		EventFn: func(ent zapcore.Entry) zapappender.SIEMEvent {
+			return zapappender.SIEMEvent{ID: "login-failed"}
+		},
+	}))
+	ent := zapcore.Entry{
+		Level:      zapcore.WarnLevel,
+		Time:       time.Unix(1600000000, 123456789),
+		LoggerName: "auth",
+		Message:    "login failed",
+		Caller:     zapcore.NewEntryCaller(0, "/src/app/auth/login.go", 42, true),
+	}
+	if _, err := a.Write([]byte("user=bob\\x\nretry\n"), ent); err != nil {
+		t.Fatal(err)
+	}
+	expected := `CEF:0|Acme\|Corp|shop|1.0|login-failed|login failed|5|rt=1600000000123` +
+		` cs1Label=logger cs1=auth cs2Label=caller cs2=auth/login.go:42 msg=user\=bob\\x\nretry` + "\n"
+	if out.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out.String())
+	}
+}
+
+func TestLEEFEnvelopingFn(t *testing.T) {
+	envFn, err := zapappender.NewLEEFEnvelopingFn(zapappender.LEEFConfig{
+		Vendor:  "Acme",
+		Product: "shop",
+		Version: "1.0",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &internal.Buffer{}
+	a := zapappender.NewEnveloping(zapappender.NewWriter(out), envFn)
+	ent := zapcore.Entry{
+		Level:      zapcore.ErrorLevel,
+		Time:       time.Unix(1600000000, 123456789),
+		LoggerName: "auth",
+	}
+	if _, err := a.Write([]byte("a=b\tc\n"), ent); err != nil {
+		t.Fatal(err)
+	}
+	expected := "LEEF:2.0|Acme|shop|1.0|auth|x09|devTime=Sep 13 2020 12:26:40.123 UTC\tsev=7\tcat=auth\tmsg=a=b\\tc\n"
+	if out.String() != expected {
+		t.Errorf("expected\n%q\ngot\n%q", expected, out.String())
+	}
+}
+
+func TestLEEFEnvelopingFn_invalidDelimiter(t *testing.T) {
+	if _, err := zapappender.NewLEEFEnvelopingFn(zapappender.LEEFConfig{Delimiter: '='}); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,217 @@
+package zapappender
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/delixfe/zapappender/internal/bufferpool"
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	_ SynchronizationAwareAppender = &SizeLimit{}
+	_ FieldsAwareAppender          = &SizeLimit{}
+)
+
+// SizeLimit limits the size of the messages forwarded to the primary appender.
+//
+// Messages exceeding the limit are truncated by default: the message is cut and the marker is appended,
+// followed by the line ending of the original message.
+//
+// With SizeLimitSplit, the message is split into parts instead. Each part is forwarded as a separate
+// message prefixed with a header carrying an id shared by all parts and the part number:
+//
+//	[<id> <part>/<parts>] <chunk>
+//
+// Messages are never cut within a UTF-8 sequence. The limits include header, marker and line ending.
+type SizeLimit struct {
+	// readonly
+	primary  Appender
+	maxBytes int
+	marker   string
+	split    bool
+	idPrefix uint64
+
+	// state
+	idCounter uint64 // atomic
+}
+
+type SizeLimitOption interface {
+	apply(*SizeLimit) error
+}
+
+type sizeLimitOptionsFunc func(*SizeLimit) error
+
+func (f sizeLimitOptionsFunc) apply(a *SizeLimit) error {
+	return f(a)
+}
+
+// SizeLimitMarker sets the marker appended to truncated messages. The default is "...(truncated)".
+func SizeLimitMarker(marker string) SizeLimitOption {
+	return sizeLimitOptionsFunc(func(a *SizeLimit) error {
+		a.marker = marker
+		return nil
+	})
+}
+
+// SizeLimitSplit splits messages exceeding the limit into multiple parts instead of truncating them.
+func SizeLimitSplit() SizeLimitOption {
+	return sizeLimitOptionsFunc(func(a *SizeLimit) error {
+		a.split = true
+		return nil
+	})
+}
+
+// sizeLimitMinPayload is the minimum number of payload bytes each message must be able to carry.
+const sizeLimitMinPayload = 16
+
+func NewSizeLimit(primary Appender, maxBytes int, options ...SizeLimitOption) (a *SizeLimit, err error) {
+	if primary == nil {
+		return nil, errors.New("primary is required")
+	}
+	a = &SizeLimit{
+		primary:  primary,
+		maxBytes: maxBytes,
+		marker:   "...(truncated)",
+	}
+	for _, option := range options {
+		err = option.apply(a)
+		if err != nil {
+			return nil, err
+		}
+	}
+	overhead := len(a.marker) + 2
+	if a.split {
+		// header with a 16 digit id and up to 3 digit part numbers
+		overhead = len("[0123456789abcdef 999/999] ") + 2
+		var seed [8]byte
+		if _, err = rand.Read(seed[:]); err != nil {
+			return nil, err
+		}
+		a.idPrefix = binary.BigEndian.Uint64(seed[:]) &^ 0xffffffff
+	}
+	if maxBytes < overhead+sizeLimitMinPayload {
+		return nil, errors.New("maxBytes is too small")
+	}
+	return a, nil
+}
+
+func (a *SizeLimit) Write(p []byte, ent zapcore.Entry) (n int, err error) {
+	return a.WriteFields(p, ent, Fields{})
+}
+
+// WriteFields forwards the fields with the truncated message or with every part.
+func (a *SizeLimit) WriteFields(p []byte, ent zapcore.Entry, fields Fields) (n int, err error) {
+	if len(p) <= a.maxBytes {
+		return writeWithFields(a.primary, p, ent, fields)
+	}
+	payload := trimNewline(p)
+	lineEnding := p[len(payload):]
+	if a.split {
+		err = a.writeParts(payload, lineEnding, ent, fields)
+	} else {
+		err = a.writeTruncated(payload, lineEnding, ent, fields)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (a *SizeLimit) writeTruncated(payload, lineEnding []byte, ent zapcore.Entry, fields Fields) error {
+	cut := utf8Cut(payload, a.maxBytes-len(a.marker)-len(lineEnding))
+	buf := bufferpool.Get()
+	defer buf.Free()
+	_, _ = buf.Write(payload[:cut])
+	buf.AppendString(a.marker)
+	_, _ = buf.Write(lineEnding)
+	_, err := writeWithFields(a.primary, buf.Bytes(), ent, fields)
+	return err
+}
+
+func (a *SizeLimit) writeParts(payload, lineEnding []byte, ent zapcore.Entry, fields Fields) (err error) {
+	id := a.idPrefix | uint64(atomic.AddUint64(&a.idCounter, 1)&0xffffffff)
+
+	// the header size depends on the number of digits of the part count
+	digits, parts := 1, 0
+	for {
+		parts = sizeLimitCountParts(payload, a.maxBytes-len(lineEnding)-sizeLimitHeaderLen(digits))
+		if len(strconv.Itoa(parts)) <= digits {
+			break
+		}
+		digits++
+	}
+
+	buf := bufferpool.Get()
+	defer buf.Free()
+	capacity := a.maxBytes - len(lineEnding) - sizeLimitHeaderLen(digits)
+	for part := 1; len(payload) > 0; part++ {
+		cut := utf8Cut(payload, capacity)
+		buf.Reset()
+		buf.AppendByte('[')
+		var idHex [16]byte
+		for i := range idHex {
+			idHex[i] = _hex[(id>>(60-4*uint(i)))&0xf]
+		}
+		_, _ = buf.Write(idHex[:])
+		buf.AppendByte(' ')
+		buf.AppendInt(int64(part))
+		buf.AppendByte('/')
+		buf.AppendInt(int64(parts))
+		buf.AppendString("] ")
+		_, _ = buf.Write(payload[:cut])
+		_, _ = buf.Write(lineEnding)
+		_, writeErr := writeWithFields(a.primary, buf.Bytes(), ent, fields)
+		err = multierr.Append(err, writeErr)
+		payload = payload[cut:]
+	}
+	return err
+}
+
+// sizeLimitCountParts returns the number of parts of at most capacity bytes payload is split into.
+func sizeLimitCountParts(payload []byte, capacity int) int {
+	parts := 0
+	for len(payload) > 0 {
+		payload = payload[utf8Cut(payload, capacity):]
+		parts++
+	}
+	return parts
+}
+
+// sizeLimitHeaderLen returns the maximum length of a part header with part numbers of digits digits.
+func sizeLimitHeaderLen(digits int) int {
+	return len("[0123456789abcdef /] ") + 2*digits
+}
+
+// utf8Cut returns the largest index <= max not splitting a UTF-8 sequence of p.
+func utf8Cut(p []byte, max int) int {
+	if max >= len(p) {
+		return len(p)
+	}
+	cut := max
+	for cut > 0 && cut > max-utf8.UTFMax && !utf8.RuneStart(p[cut]) {
+		cut--
+	}
+	if cut == 0 || !utf8.RuneStart(p[cut]) {
+		// invalid UTF-8 or no room for a single rune, cut anywhere
+		return max
+	}
+	return cut
+}
+
+func (a *SizeLimit) WantsFields() bool {
+	return WantsFields(a.primary)
+}
+
+func (a *SizeLimit) Sync() error {
+	return a.primary.Sync()
+}
+
+func (a *SizeLimit) Synchronized() bool {
+	return Synchronized(a.primary)
+}
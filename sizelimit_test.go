@@ -0,0 +1,89 @@
+package zapappender_test
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/delixfe/zapappender"
+	"github.com/delixfe/zapappender/internal"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSizeLimit_truncate(t *testing.T) {
+	out := &internal.Buffer{}
+	a, err := zapappender.NewSizeLimit(zapappender.NewWriter(out), 32, zapappender.SizeLimitMarker("[cut]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = a.Write([]byte("short\n"), zapcore.Entry{})
+	n, err := a.Write([]byte(strings.Repeat("ä", 30)+"\n"), zapcore.Entry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 61 {
+		t.Errorf("expected the original length to be reported, got %d", n)
+	}
+
+	lines := out.Lines()
+	if len(lines) != 2 || lines[0] != "short" {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+	// 32 - len("[cut]") - len("\n") = 26 bytes leave room for 13 two byte runes
+	if lines[1] != strings.Repeat("ä", 13)+"[cut]" {
+		t.Errorf("unexpected truncation %q", lines[1])
+	}
+}
+
+func TestSizeLimit_split(t *testing.T) {
+	out := &internal.Buffer{}
+	a, err := zapappender.NewSizeLimit(zapappender.NewWriter(out), 50, zapappender.SizeLimitSplit())
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := strings.Repeat("0123456789€", 20)
+	if _, err := a.Write([]byte(message+"\n"), zapcore.Entry{}); err != nil {
+		t.Fatal(err)
+	}
+
+	header := regexp.MustCompile(`^\[([0-9a-f]{16}) (\d+)/(\d+)\] `)
+	var (
+		id        string
+		assembled strings.Builder
+	)
+	lines := out.Lines()
+	for i, line := range lines {
+		if len(line)+1 > 50 {
+			t.Errorf("part %d exceeds the limit: %d bytes", i+1, len(line)+1)
+		}
+		m := header.FindStringSubmatch(line)
+		if m == nil {
+			t.Fatalf("part without header %q", line)
+		}
+		if id == "" {
+			id = m[1]
+		} else if m[1] != id {
+			t.Errorf("expected shared id %s, got %s", id, m[1])
+		}
+		if m[2] != strconv.Itoa(i+1) || m[3] != strconv.Itoa(len(lines)) {
+			t.Errorf("unexpected part numbers in %q", line)
+		}
+		chunk := line[len(m[0]):]
+		if !utf8.ValidString(chunk) {
+			t.Errorf("part %d is cut within a rune: %q", i+1, chunk)
+		}
+		assembled.WriteString(chunk)
+	}
+	if assembled.String() != message {
+		t.Errorf("reassembled message differs\n%s\n%s", message, assembled.String())
+	}
+}
+
+func TestSizeLimit_tooSmall(t *testing.T) {
+	if _, err := zapappender.NewSizeLimit(zapappender.NewDiscard(), 10); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,253 @@
+package zapappender
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/delixfe/zapappender/internal/bufferpool"
+	"go.uber.org/multierr"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// ErrSplunkHECAckTimeout is returned if the indexer did not acknowledge a batch in time.
+var ErrSplunkHECAckTimeout = errors.New("splunk hec acknowledgement timed out")
+
+// SplunkHECConfig configures the Splunk HEC appender.
+type SplunkHECConfig struct {
+	// Token is the HEC token.
+	Token string
+	// Host, Source, SourceType and Index are added to every event if set.
+	Host       string
+	Source     string
+	SourceType string
+	Index      string
+
+	// Ack enables indexer acknowledgement. Each batch is only considered sent after it was acknowledged.
+	// The acknowledgement is awaited synchronously: a flush takes an additional request and, if the batch
+	// is not yet indexed, AckPollInterval per further query.
+	Ack bool
+	// Channel is the GUID of the data channel. A random channel is used if it is empty.
+	Channel string
+	// AckTimeout limits the time waiting for an acknowledgement. The default is 30 seconds.
+	AckTimeout time.Duration
+	// AckPollInterval is the interval the acknowledgement status is queried. The default is 1 second.
+	AckPollInterval time.Duration
+}
+
+// SplunkHECError is returned if HEC responded with an error code.
+type SplunkHECError struct {
+	StatusCode int
+	Code       int
+	Text       string
+}
+
+func (e *SplunkHECError) Error() string {
+	return fmt.Sprintf("splunk hec error %d (http status %d): %s", e.Code, e.StatusCode, e.Text)
+}
+
+var _ SynchronizationAwareAppender = &SplunkHEC{}
+
+// SplunkHEC sends messages to the Splunk HTTP Event Collector.
+//
+// Each message is wrapped in a HEC event envelope with the entry time. Messages that are JSON objects
+// are embedded as JSON, others as string. Empty messages are skipped as HEC rejects empty events.
+// Events are sent in batches.
+// Server busy and internal errors are retried, other HEC errors are returned as SplunkHECError.
+type SplunkHEC struct {
+	eventURL  string
+	ackURL    string
+	config    SplunkHECConfig
+	header    http.Header
+	metadata  string // pre-encoded host, source, sourcetype and index
+	transport *httpTransport
+	batch     *batcher
+}
+
+// NewSplunkHEC creates a SplunkHEC appender sending to the collector at url, e.g. https://splunk:8088.
+func NewSplunkHEC(url string, config SplunkHECConfig, options ...HTTPOption) (*SplunkHEC, error) {
+	if url == "" {
+		return nil, errors.New("url is required")
+	}
+	if config.Token == "" {
+		return nil, errors.New("token is required")
+	}
+	if config.AckTimeout == 0 {
+		config.AckTimeout = 30 * time.Second
+	}
+	if config.AckPollInterval == 0 {
+		config.AckPollInterval = time.Second
+	}
+	if config.AckTimeout < 0 || config.AckPollInterval < 0 {
+		return nil, errors.New("ack timeout and poll interval must be positive")
+	}
+	if config.Ack && config.Channel == "" {
+		channel, err := randomUUID()
+		if err != nil {
+			return nil, err
+		}
+		config.Channel = channel
+	}
+	transport, err := newHTTPTransport(options)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make(http.Header)
+	header.Set("Authorization", "Splunk "+config.Token)
+	if config.Channel != "" {
+		header.Set("X-Splunk-Request-Channel", config.Channel)
+	}
+
+	metadata := bufferpool.Get()
+	defer metadata.Free()
+	for _, field := range [][2]string{
+		{"host", config.Host},
+		{"source", config.Source},
+		{"sourcetype", config.SourceType},
+		{"index", config.Index},
+	} {
+		if field[1] == "" {
+			continue
+		}
+		metadata.AppendByte(',')
+		appendJSONString(metadata, field[0])
+		metadata.AppendByte(':')
+		appendJSONString(metadata, field[1])
+	}
+
+	base := strings.TrimSuffix(url, "/")
+	a := &SplunkHEC{
+		eventURL:  base + "/services/collector/event",
+		ackURL:    base + "/services/collector/ack",
+		config:    config,
+		header:    header,
+		metadata:  metadata.String(),
+		transport: transport,
+	}
+	a.batch = transport.newBatcher(a.send)
+	return a, nil
+}
+
+func (a *SplunkHEC) Write(p []byte, ent zapcore.Entry) (n int, err error) {
+	buf := bufferpool.Get()
+	_, _ = buf.Write(p)
+	if err = a.batch.add("", buf, ent); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (a *SplunkHEC) appendEvent(body *buffer.Buffer, item batchItem) {
+	body.AppendString(`{"time":`)
+	appendUnixFraction(body, item.ent.Time, 6)
+	body.AppendString(a.metadata)
+	body.AppendString(`,"event":`)
+	msg := trimNewline(item.buf.Bytes())
+	if len(msg) > 0 && msg[0] == '{' && json.Valid(msg) {
+		_, _ = body.Write(msg)
+	} else {
+		appendJSONBytes(body, msg)
+	}
+	body.AppendString("}\n")
+}
+
+type splunkHECResponse struct {
+	Text  string `json:"text"`
+	Code  int    `json:"code"`
+	AckID *int64 `json:"ackId"`
+}
+
+// send is called by the batcher, the calls are serialized.
+func (a *SplunkHEC) send(_ string, items []batchItem) error {
+	body := bufferpool.Get()
+	defer body.Free()
+	for _, item := range items {
+		if len(bytes.TrimSpace(item.buf.Bytes())) > 0 {
+			a.appendEvent(body, item)
+		}
+	}
+	if body.Len() == 0 {
+		return nil
+	}
+
+	respBody, err := a.transport.post(a.eventURL, "application/json", body.Bytes(), a.header)
+	if err != nil {
+		var statusErr *HTTPStatusError
+		var resp splunkHECResponse
+		if errors.As(err, &statusErr) && json.Unmarshal([]byte(statusErr.Body), &resp) == nil && resp.Text != "" {
+			err = &SplunkHECError{StatusCode: statusErr.StatusCode, Code: resp.Code, Text: resp.Text}
+		}
+		return multierr.Append(err, a.transport.forwardFailed(items))
+	}
+	if !a.config.Ack {
+		return nil
+	}
+
+	var resp splunkHECResponse
+	if err = json.Unmarshal(respBody, &resp); err != nil || resp.AckID == nil {
+		err = fmt.Errorf("response contains no ackId: %s", respBody)
+		return multierr.Append(err, a.transport.forwardFailed(items))
+	}
+	if err = a.waitForAck(*resp.AckID); err != nil {
+		return multierr.Append(err, a.transport.forwardFailed(items))
+	}
+	return nil
+}
+
+func (a *SplunkHEC) waitForAck(ackID int64) error {
+	query := []byte(`{"acks":[` + strconv.FormatInt(ackID, 10) + `]}`)
+	key := strconv.FormatInt(ackID, 10)
+	deadline := time.Now().Add(a.config.AckTimeout)
+	for {
+		respBody, err := a.transport.post(a.ackURL, "application/json", query, a.header)
+		if err != nil {
+			return err
+		}
+		var resp struct {
+			Acks map[string]bool `json:"acks"`
+		}
+		if err = json.Unmarshal(respBody, &resp); err != nil {
+			return fmt.Errorf("invalid ack response: %w", err)
+		}
+		if resp.Acks[key] {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return ErrSplunkHECAckTimeout
+		}
+		time.Sleep(a.config.AckPollInterval)
+	}
+}
+
+// Sync sends the pending batch.
+func (a *SplunkHEC) Sync() error {
+	return a.batch.flush()
+}
+
+func (a *SplunkHEC) Synchronized() bool {
+	return true
+}
+
+// Shutdown stops the periodic flushing and sends the pending batch.
+func (a *SplunkHEC) Shutdown() error {
+	return a.batch.stop()
+}
+
+// randomUUID returns a random version 4 UUID.
+func randomUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -0,0 +1,136 @@
+package zapappender_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/delixfe/zapappender"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSplunkHEC_ack(t *testing.T) {
+	polls := int32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Splunk token" {
+			t.Errorf("unexpected authorization %q", r.Header.Get("Authorization"))
+		}
+		if r.Header.Get("X-Splunk-Request-Channel") == "" {
+			t.Error("missing channel")
+		}
+		body, _ := io.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/services/collector/event":
+			expected := `{"time":1600000000.123456,"host":"web-1","sourcetype":"_json","event":{"msg":"json"}}` + "\n" +
+				`{"time":1600000000.123456,"host":"web-1","sourcetype":"_json","event":"plain \"text\""}` + "\n"
+			if string(body) != expected {
+				t.Errorf("unexpected body\n%s", body)
+			}
+			_, _ = w.Write([]byte(`{"text":"Success","code":0,"ackId":7}`))
+		case "/services/collector/ack":
+			if string(body) != `{"acks":[7]}` {
+				t.Errorf("unexpected ack query %s", body)
+			}
+			if atomic.AddInt32(&polls, 1) < 2 {
+				_, _ = w.Write([]byte(`{"acks":{"7":false}}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"acks":{"7":true}}`))
+		default:
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	a, err := zapappender.NewSplunkHEC(server.URL, zapappender.SplunkHECConfig{
+		Token:           "token",
+		Host:            "web-1",
+		SourceType:      "_json",
+		Ack:             true,
+		AckPollInterval: time.Millisecond,
+	}, zapappender.HTTPFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Shutdown()
+
+	ts := time.Unix(1600000000, 123456789)
+	_, _ = a.Write([]byte(`{"msg":"json"}`+"\n"), zapcore.Entry{Time: ts})
+	_, _ = a.Write([]byte(`plain "text"`+"\n"), zapcore.Entry{Time: ts})
+	_, _ = a.Write([]byte(" \t\n"), zapcore.Entry{Time: ts})
+	if err := a.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&polls) != 2 {
+		t.Errorf("expected 2 ack polls, got %d", polls)
+	}
+}
+
+func TestSplunkHEC_ackedOnFirstPoll_doesNotWait(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/services/collector/ack" {
+			_, _ = w.Write([]byte(`{"acks":{"1":true}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"text":"Success","code":0,"ackId":1}`))
+	}))
+	defer server.Close()
+
+	a, err := zapappender.NewSplunkHEC(server.URL, zapappender.SplunkHECConfig{
+		Token:           "token",
+		Ack:             true,
+		AckPollInterval: time.Hour,
+	}, zapappender.HTTPFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Shutdown()
+
+	_, _ = a.Write([]byte("msg\n"), zapcore.Entry{})
+	if err := a.Sync(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSplunkHEC_emptyMessages_notSent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %q", r.URL.Path)
+	}))
+	defer server.Close()
+
+	a, err := zapappender.NewSplunkHEC(server.URL, zapappender.SplunkHECConfig{Token: "token"}, zapappender.HTTPFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Shutdown()
+
+	_, _ = a.Write([]byte("\n"), zapcore.Entry{})
+	_, _ = a.Write([]byte("  "), zapcore.Entry{})
+	if err := a.Sync(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSplunkHEC_invalidToken_returnsHECError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"text":"Invalid token","code":4}`))
+	}))
+	defer server.Close()
+
+	a, err := zapappender.NewSplunkHEC(server.URL, zapappender.SplunkHECConfig{Token: "wrong"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Shutdown()
+	_, _ = a.Write([]byte("msg\n"), zapcore.Entry{})
+
+	var hecErr *zapappender.SplunkHECError
+	if err := a.Sync(); !errors.As(err, &hecErr) || hecErr.Code != 4 || hecErr.StatusCode != http.StatusForbidden {
+		t.Errorf("expected a SplunkHECError with code 4, got %v", err)
+	}
+}
@@ -0,0 +1,26 @@
+package zapappender
+
+import (
+	"github.com/delixfe/zapappender/internal/bufferpool"
+	"go.uber.org/zap/zapcore"
+)
+
+// writeSynthetic writes a message created by an appender itself, like a summary of suppressed messages.
+// If enc is nil, only the message followed by a newline is written.
+func writeSynthetic(a Appender, enc zapcore.Encoder, ent zapcore.Entry) error {
+	if enc != nil {
+		buf, err := enc.EncodeEntry(ent, nil)
+		if err != nil {
+			return err
+		}
+		_, err = a.Write(buf.Bytes(), ent)
+		buf.Free()
+		return err
+	}
+	buf := bufferpool.Get()
+	defer buf.Free()
+	buf.AppendString(ent.Message)
+	buf.AppendByte('\n')
+	_, err := a.Write(buf.Bytes(), ent)
+	return err
+}
@@ -0,0 +1,204 @@
+package zapappender
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// templateRenderFn renders one segment of a compiled template.
+type templateRenderFn func(buf *buffer.Buffer, payload []byte, ent zapcore.Entry)
+
+var templateTimeLayouts = map[string]string{
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"ISO8601":     "2006-01-02T15:04:05.000Z0700",
+	"RFC1123":     time.RFC1123,
+	"Kitchen":     time.Kitchen,
+	"Stamp":       time.Stamp,
+	"StampMilli":  time.StampMilli,
+	"StampMicro":  time.StampMicro,
+}
+
+// NewTemplateEnvelopingFn compiles template into an EnvelopingFn.
+//
+// The template is literal text with directives of the form %{name} or %{name:argument}:
+//
+//	%{time}           entry time as RFC3339Nano; the argument is one of RFC3339, RFC3339Nano, ISO8601,
+//	                  RFC1123, Kitchen, Stamp, StampMilli, StampMicro, unix, unixmilli, unixnano
+//	                  or a time.Format layout
+//	%{level}          level in lower case; %{level:upper} for upper case
+//	%{logger}         logger name
+//	%{caller}         caller as trimmed path; %{caller:full} for the full path, %{caller:func} for the function
+//	%{message}        entry message
+//	%{stack}          entry stack
+//	%{payload}        encoded message without its line ending
+//	%%                a literal percent sign
+//
+// The output is terminated with a newline if the encoded message was.
+// The template is compiled once; rendering does not allocate.
+func NewTemplateEnvelopingFn(template string) (EnvelopingFn, error) {
+	segments, err := compileTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+	return func(p []byte, ent zapcore.Entry, output *buffer.Buffer) error {
+		payload := trimNewline(p)
+		for _, segment := range segments {
+			segment(output, payload, ent)
+		}
+		if len(payload) < len(p) {
+			output.AppendByte('\n')
+		}
+		return nil
+	}, nil
+}
+
+// NewEnvelopingTemplate creates an Enveloping formatting messages with a template as described by NewTemplateEnvelopingFn.
+func NewEnvelopingTemplate(inner Appender, template string) (*Enveloping, error) {
+	envFn, err := NewTemplateEnvelopingFn(template)
+	if err != nil {
+		return nil, err
+	}
+	return NewEnveloping(inner, envFn), nil
+}
+
+func compileTemplate(template string) ([]templateRenderFn, error) {
+	var (
+		segments []templateRenderFn
+		literal  strings.Builder
+	)
+	flushLiteral := func() {
+		if literal.Len() == 0 {
+			return
+		}
+		s := literal.String()
+		literal.Reset()
+		segments = append(segments, func(buf *buffer.Buffer, _ []byte, _ zapcore.Entry) {
+			buf.AppendString(s)
+		})
+	}
+
+	for i := 0; i < len(template); i++ {
+		c := template[i]
+		if c != '%' {
+			literal.WriteByte(c)
+			continue
+		}
+		if i+1 < len(template) && template[i+1] == '%' {
+			literal.WriteByte('%')
+			i++
+			continue
+		}
+		if i+1 >= len(template) || template[i+1] != '{' {
+			return nil, fmt.Errorf("template: %% at offset %d must be followed by { or %%", i)
+		}
+		end := strings.IndexByte(template[i:], '}')
+		if end == -1 {
+			return nil, fmt.Errorf("template: unterminated directive at offset %d", i)
+		}
+		directive := template[i+2 : i+end]
+		segment, err := compileTemplateDirective(directive)
+		if err != nil {
+			return nil, err
+		}
+		flushLiteral()
+		segments = append(segments, segment)
+		i += end
+	}
+	flushLiteral()
+	return segments, nil
+}
+
+func compileTemplateDirective(directive string) (templateRenderFn, error) {
+	name, arg := directive, ""
+	if idx := strings.IndexByte(directive, ':'); idx != -1 {
+		name, arg = directive[:idx], directive[idx+1:]
+	}
+	switch name {
+	case "time":
+		return compileTemplateTime(arg), nil
+	case "level":
+		switch arg {
+		case "", "lower":
+			return func(buf *buffer.Buffer, _ []byte, ent zapcore.Entry) {
+				buf.AppendString(ent.Level.String())
+			}, nil
+		case "upper":
+			return func(buf *buffer.Buffer, _ []byte, ent zapcore.Entry) {
+				buf.AppendString(ent.Level.CapitalString())
+			}, nil
+		}
+	case "logger":
+		if arg == "" {
+			return func(buf *buffer.Buffer, _ []byte, ent zapcore.Entry) {
+				buf.AppendString(ent.LoggerName)
+			}, nil
+		}
+	case "caller":
+		switch arg {
+		case "", "short":
+			return func(buf *buffer.Buffer, _ []byte, ent zapcore.Entry) {
+				appendTrimmedCaller(buf, ent.Caller)
+			}, nil
+		case "full":
+			return func(buf *buffer.Buffer, _ []byte, ent zapcore.Entry) {
+				appendFullCaller(buf, ent.Caller)
+			}, nil
+		case "func":
+			return func(buf *buffer.Buffer, _ []byte, ent zapcore.Entry) {
+				buf.AppendString(ent.Caller.Function)
+			}, nil
+		}
+	case "message":
+		if arg == "" {
+			return func(buf *buffer.Buffer, _ []byte, ent zapcore.Entry) {
+				buf.AppendString(ent.Message)
+			}, nil
+		}
+	case "stack":
+		if arg == "" {
+			return func(buf *buffer.Buffer, _ []byte, ent zapcore.Entry) {
+				buf.AppendString(ent.Stack)
+			}, nil
+		}
+	case "payload":
+		if arg == "" {
+			return func(buf *buffer.Buffer, payload []byte, _ zapcore.Entry) {
+				_, _ = buf.Write(payload)
+			}, nil
+		}
+	default:
+		return nil, fmt.Errorf("template: unknown directive %q", name)
+	}
+	return nil, fmt.Errorf("template: unknown argument %q for directive %q", arg, name)
+}
+
+func compileTemplateTime(arg string) templateRenderFn {
+	switch arg {
+	case "unix":
+		return func(buf *buffer.Buffer, _ []byte, ent zapcore.Entry) {
+			buf.AppendInt(ent.Time.Unix())
+		}
+	case "unixmilli":
+		return func(buf *buffer.Buffer, _ []byte, ent zapcore.Entry) {
+			buf.AppendInt(ent.Time.UnixNano() / int64(time.Millisecond))
+		}
+	case "unixnano":
+		return func(buf *buffer.Buffer, _ []byte, ent zapcore.Entry) {
+			buf.AppendInt(ent.Time.UnixNano())
+		}
+	}
+	layout := time.RFC3339Nano
+	if named, ok := templateTimeLayouts[arg]; ok {
+		layout = named
+	} else if arg != "" {
+		layout = arg
+	}
+	return func(buf *buffer.Buffer, _ []byte, ent zapcore.Entry) {
+		buf.AppendTime(ent.Time, layout)
+	}
+}
@@ -0,0 +1,72 @@
+package zapappender_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/delixfe/zapappender"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestTemplateEnvelopingFn(t *testing.T) {
+	ent := zapcore.Entry{
+		Level:      zapcore.WarnLevel,
+		Time:       time.Date(2020, 9, 13, 12, 26, 40, 123456789, time.UTC),
+		LoggerName: "http",
+		Message:    "msg",
+		Caller:     zapcore.NewEntryCaller(0, "/src/app/http/handler.go", 42, true),
+	}
+	tests := []struct {
+		template string
+		payload  string
+		expected string
+	}{
+		{"%{time:RFC3339} %{level:upper} [%{logger}] %{caller:short} %{payload}", "hello\n",
+			"2020-09-13T12:26:40Z WARN [http] http/handler.go:42 hello\n"},
+		{"%{time:unixmilli}|%{level}|%{caller:full}|%{message}", "hello",
+			"1600000000123|warn|/src/app/http/handler.go:42|msg"},
+		{"%{time:15:04:05.000} 100%% %{payload}!", "x\r\n", "12:26:40.123 100% x!\n"},
+		{"%{time}", "", "2020-09-13T12:26:40.123456789Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			envFn, err := zapappender.NewTemplateEnvelopingFn(tt.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf := &buffer.Buffer{}
+			if err := envFn([]byte(tt.payload), ent, buf); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestTemplateEnvelopingFn_invalid(t *testing.T) {
+	for _, template := range []string{"%{unknown}", "%{level:mixed}", "%{payload", "100%", "%x"} {
+		if _, err := zapappender.NewTemplateEnvelopingFn(template); err == nil {
+			t.Errorf("expected an error for %q", template)
+		}
+	}
+}
+
+func TestTemplateEnvelopingFn_doesNotAllocate(t *testing.T) {
+	envFn, err := zapappender.NewTemplateEnvelopingFn("%{time:RFC3339} %{level:upper} [%{logger}] %{caller} %{payload}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ent := zapcore.Entry{Time: time.Now(), LoggerName: "http", Caller: zapcore.NewEntryCaller(0, "a/b/c.go", 1, true)}
+	p := []byte("hello\n")
+	buf := &buffer.Buffer{}
+	allocs := testing.AllocsPerRun(100, func() {
+		buf.Reset()
+		_ = envFn(p, ent, buf)
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %v", allocs)
+	}
+}